@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/services/discord"
+
+	"github.com/gin-gonic/gin"
+)
+
+// discordInteraction is the bot's interactions endpoint URL. Discord signs
+// every request and expects the response within three seconds.
+func (h *Handler) discordInteraction(c *gin.Context) {
+	if !h.discordService.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Discord bot is not enabled"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request"})
+		return
+	}
+
+	err = h.discordService.VerifyInteraction(c.GetHeader("X-Signature-Ed25519"), c.GetHeader("X-Signature-Timestamp"), body)
+	if err != nil {
+		log.Printf("Rejected Discord interaction: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid request signature"})
+		return
+	}
+
+	var interaction discord.Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	response, err := h.discordService.HandleInteraction(&interaction)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			return
+		}
+		log.Printf("Failed to answer Discord interaction %s: %v", interaction.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to answer command"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
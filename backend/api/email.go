@@ -0,0 +1,121 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/email"
+
+	"github.com/gin-gonic/gin"
+)
+
+var confirmUnsubscribePage = template.Must(template.New("confirm-unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe - quixit</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>{{if .All}}Stop getting all emails from quixit?{{else}}Stop getting {{.What}} emails from quixit?{{end}}</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+var unsubscribedPage = template.Must(template.New("unsubscribed").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribed - quixit</title></head>
+<body>
+<p>{{if .All}}You will no longer get emails from quixit.{{else}}You will no longer get {{.What}} emails from quixit.{{end}}</p>
+</body>
+</html>
+`))
+
+// emailKindNames describes email kinds on the unsubscribe page
+var emailKindNames = map[string]string{
+	models.EmailPackOpened:    "pack opened",
+	models.EmailUploadClosing: "upload reminder",
+	models.EmailWeeklyDigest:  "weekly digest",
+}
+
+func (h *Handler) getEmailPreferences(c *gin.Context) {
+	prefs, err := h.emailService.Preferences(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get email preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func (h *Handler) updateEmailPreferences(c *gin.Context) {
+	var input email.PreferencesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	prefs, err := h.emailService.UpdatePreferences(uint(c.GetInt("user_id")), input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// confirmUnsubscribe answers people following the link in an email with a
+// page that POSTs back to unsubscribe. Following the link must not change
+// anything, since mail scanners and link prefetchers follow it too
+// (RFC 8058).
+func (h *Handler) confirmUnsubscribe(c *gin.Context) {
+	kind := c.DefaultQuery("kind", email.UnsubscribeAll)
+	if c.Query("token") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsubscribe token is required"})
+		return
+	}
+	if kind != email.UnsubscribeAll && emailKindNames[kind] == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown email kind"})
+		return
+	}
+
+	query := url.Values{"token": {c.Query("token")}, "kind": {kind}}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	confirmUnsubscribePage.Execute(c.Writer, gin.H{
+		"Action": "/api/email/unsubscribe?" + query.Encode(),
+		"All":    kind == email.UnsubscribeAll,
+		"What":   emailKindNames[kind],
+	})
+}
+
+// unsubscribe handles one-click unsubscribe POSTs from mail clients
+// (RFC 8058) and the form on the confirmation page, which gets a page back
+func (h *Handler) unsubscribe(c *gin.Context) {
+	kind := c.DefaultQuery("kind", email.UnsubscribeAll)
+	if _, err := h.emailService.Unsubscribe(c.Query("token"), kind); err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe link not found"})
+		case errors.IsValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		}
+		return
+	}
+
+	if c.PostForm("List-Unsubscribe") == "One-Click" {
+		c.JSON(http.StatusOK, gin.H{"unsubscribed": kind})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	unsubscribedPage.Execute(c.Writer, gin.H{
+		"All":  kind == email.UnsubscribeAll,
+		"What": emailKindNames[kind],
+	})
+}
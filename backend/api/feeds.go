@@ -0,0 +1,148 @@
+package api
+
+import (
+	stderrors "errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rssContentType = "application/rss+xml; charset=utf-8"
+
+func (h *Handler) getFeedToken(c *gin.Context) {
+	token, err := h.feedService.Token(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get feed token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feedURLs(token))
+}
+
+func (h *Handler) rotateFeedToken(c *gin.Context) {
+	token, err := h.feedService.RotateToken(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate feed token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.feedURLs(token))
+}
+
+func (h *Handler) feedURLs(token string) gin.H {
+	query := "?token=" + url.QueryEscape(token)
+	return gin.H{
+		"token":       token,
+		"feedUrl":     h.config.PublicURL + "/api/feeds/submissions.rss" + query,
+		"packFeedUrl": h.config.PublicURL + "/api/feeds/packs/{id}/submissions.rss" + query,
+	}
+}
+
+// feedUser authenticates a feed request by its token query parameter,
+// writing an error response if it is missing or invalid
+func (h *Handler) feedUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.feedService.Authenticate(c.Query("token"))
+	if err != nil {
+		var apiErr *errors.APIError
+		if stderrors.As(err, &apiErr) && apiErr.Type == errors.TypeAuthentication {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": apiErr.Message})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check feed token"})
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *Handler) getGlobalFeed(c *gin.Context) {
+	user, ok := h.feedUser(c)
+	if !ok {
+		return
+	}
+
+	feed, err := h.feedService.GlobalFeed(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, rssContentType, feed)
+}
+
+func (h *Handler) getPackFeed(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	user, ok := h.feedUser(c)
+	if !ok {
+		return
+	}
+
+	feed, err := h.feedService.PackFeed(uint(id), user)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build feed"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, rssContentType, feed)
+}
+
+// getFeedAudio serves a submission enclosure to podcast players, which
+// authenticate with the feed token instead of a bearer token
+func (h *Handler) getFeedAudio(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	if _, ok := h.feedUser(c); !ok {
+		return
+	}
+
+	submission, err := h.submissionService.GetSubmission(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(submission.Filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%s", submission.Filename))
+	c.Header("Cache-Control", "private, no-cache")
+	h.serveVerifiedFile(c, submission.FilePath, submission.SHA256, submission.Filename, contentType)
+}
+
+// getPackCalendar serves pack windows as an iCalendar feed. Window dates
+// are not private, so calendar apps can subscribe without a token.
+func (h *Handler) getPackCalendar(c *gin.Context) {
+	calendar, err := h.feedService.PackCalendar()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	c.Header("Content-Disposition", "inline; filename=quixit.ics")
+	c.Header("Cache-Control", "public, max-age=900")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar)
+}
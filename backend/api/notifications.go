@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxNotificationsPageSize = 100
+
+func (h *Handler) listNotifications(c *gin.Context) {
+	userID := uint(c.GetInt("user_id"))
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > maxNotificationsPageSize {
+		limit = maxNotificationsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	notifications, err := h.notificationService.List(userID, c.Query("unread") == "true", limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list notifications"})
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unreadCount":   unread,
+	})
+}
+
+func (h *Handler) markNotificationRead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(uint(c.GetInt("user_id")), uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func (h *Handler) markAllNotificationsRead(c *gin.Context) {
+	marked, err := h.notificationService.MarkAllRead(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/services/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxHistoryPageSize = 100
+
+	// recentHistorySize is how many samples and submissions a profile shows
+	recentHistorySize = 5
+)
+
+func (h *Handler) getUserProfile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	u, err := h.userService.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	viewer := viewerFrom(c)
+	samples, sampleCount, err := h.packService.ListUserSamples(u.ID, viewer, recentHistorySize, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list samples"})
+		return
+	}
+	submissions, submissionCount, err := h.submissionService.ListUserSubmissions(u.ID, viewer, recentHistorySize, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile":           user.NewProfile(u),
+		"sampleCount":       sampleCount,
+		"submissionCount":   submissionCount,
+		"recentSamples":     samples,
+		"recentSubmissions": submissions,
+	})
+}
+
+func (h *Handler) listUserSamples(c *gin.Context) {
+	id, ok := h.profileUserID(c)
+	if !ok {
+		return
+	}
+	limit, offset := historyPage(c)
+
+	samples, total, err := h.packService.ListUserSamples(id, viewerFrom(c), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list samples"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"samples": samples,
+		"total":   total,
+	})
+}
+
+func (h *Handler) listUserSubmissions(c *gin.Context) {
+	id, ok := h.profileUserID(c)
+	if !ok {
+		return
+	}
+	limit, offset := historyPage(c)
+
+	submissions, total, err := h.submissionService.ListUserSubmissions(id, viewerFrom(c), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"submissions": submissions,
+		"total":       total,
+	})
+}
+
+func (h *Handler) updateCurrentUser(c *gin.Context) {
+	var input user.ProfileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	u, err := h.userService.UpdateProfile(uint(c.GetInt("user_id")), input)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			return
+		}
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	h.writeCurrentUser(c, u)
+}
+
+// profileUserID parses the user ID path parameter and checks the user
+// exists, writing the error response when it does not
+func (h *Handler) profileUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+
+	u, err := h.userService.GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return 0, false
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	return u.ID, true
+}
+
+func historyPage(c *gin.Context) (limit, offset int) {
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > maxHistoryPageSize {
+		limit = maxHistoryPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
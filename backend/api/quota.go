@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaErrorResponse writes the response for a failed quota check
+func quotaErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.IsAuthorizationError(err):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check upload quota"})
+	}
+}
+
+func (h *Handler) getUsage(c *gin.Context) {
+	report, err := h.quotaService.GetReport(uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) getUserQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	quota, err := h.quotaService.GetUserQuota(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
+
+func (h *Handler) setUserQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UserQuota
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	quota, err := h.quotaService.SetUserQuota(uint(id), req)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
+
+func (h *Handler) deleteUserQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.quotaService.DeleteUserQuota(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quota"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) setPackQuota(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	var quota models.Quota
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	pack, err := h.packService.SetUploadQuota(uint(id), quota)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update upload quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
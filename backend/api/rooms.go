@@ -0,0 +1,137 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// roomUpgrader accepts WebSocket connections from the frontend, whether it
+// is served by the backend or the dev server
+var roomUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || middleware.AllowedOrigin(origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	},
+}
+
+func (h *Handler) listRooms(c *gin.Context) {
+	rooms, err := h.partyService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rooms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rooms)
+}
+
+func (h *Handler) createRoom(c *gin.Context) {
+	var req struct {
+		PackID uint   `json:"packId" binding:"required"`
+		Title  string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	room, err := h.partyService.Create(viewerFrom(c), req.PackID, req.Title)
+	if err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+		case errors.IsValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, room)
+}
+
+func (h *Handler) getRoom(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	room, err := h.partyService.Get(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
+
+func (h *Handler) closeRoom(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if err := h.partyService.Close(uint(id), viewerFrom(c)); err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+		case errors.IsAuthorizationError(err):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can close the room"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close room"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// joinRoom upgrades to a WebSocket carrying the room's playback state,
+// chat and reactions
+func (h *Handler) joinRoom(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	room, err := h.partyService.Get(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get room"})
+		return
+	}
+
+	viewer := viewerFrom(c)
+	conn, err := roomUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // the upgrader has already responded
+	}
+
+	if err := h.partyService.Serve(room, conn, viewer); err != nil {
+		log.Printf("Failed to join room %d: %v", room.ID, err)
+	}
+}
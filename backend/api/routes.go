@@ -1,42 +1,144 @@
 package api
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"sample-exchange/backend/audio"
 	"sample-exchange/backend/config"
 	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
 	"sample-exchange/backend/middleware"
 	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/discord"
+	"sample-exchange/backend/services/email"
+	"sample-exchange/backend/services/feed"
+	"sample-exchange/backend/services/gc"
+	"sample-exchange/backend/services/notification"
+	"sample-exchange/backend/services/party"
+	"sample-exchange/backend/services/preview"
+	"sample-exchange/backend/services/quota"
 	"sample-exchange/backend/services/samplepack"
 	"sample-exchange/backend/services/submission"
+	"sample-exchange/backend/services/upload"
+	"sample-exchange/backend/services/user"
+	"sample-exchange/backend/services/webhook"
 	"sample-exchange/backend/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	packService       *samplepack.Service
-	submissionService *submission.Service
-	storage           storage.Storage
-	config            *config.Config
+	packService         *samplepack.Service
+	submissionService   *submission.Service
+	previewService      *preview.Service
+	uploadService       *upload.Service
+	quotaService        *quota.Service
+	feedService         *feed.Service
+	webhookService      *webhook.Service
+	discordService      *discord.Service
+	emailService        *email.Service
+	notificationService *notification.Service
+	partyService        *party.Service
+	userService         *user.Service
+	events              *events.Bus
+	queue               *jobs.Queue
+	storage             storage.Storage
+	config              *config.Config
 }
 
-func NewHandler(packService *samplepack.Service, submissionService *submission.Service, storage storage.Storage, cfg *config.Config) *Handler {
+func NewHandler(packService *samplepack.Service, submissionService *submission.Service, previewService *preview.Service, uploadService *upload.Service, quotaService *quota.Service, feedService *feed.Service, webhookService *webhook.Service, discordService *discord.Service, emailService *email.Service, notificationService *notification.Service, partyService *party.Service, userService *user.Service, bus *events.Bus, queue *jobs.Queue, storage storage.Storage, cfg *config.Config) *Handler {
 	return &Handler{
-		packService:       packService,
-		submissionService: submissionService,
-		storage:           storage,
-		config:            cfg,
+		packService:         packService,
+		submissionService:   submissionService,
+		previewService:      previewService,
+		uploadService:       uploadService,
+		quotaService:        quotaService,
+		feedService:         feedService,
+		webhookService:      webhookService,
+		discordService:      discordService,
+		emailService:        emailService,
+		notificationService: notificationService,
+		partyService:        partyService,
+		userService:         userService,
+		events:              bus,
+		queue:               queue,
+		storage:             storage,
+		config:              cfg,
 	}
 }
 
-func Init(r *gin.Engine, store storage.Storage, cfg *config.Config) {
-	packService := samplepack.NewService(cfg)
-	submissionService := submission.NewService(cfg, packService)
-	handler := NewHandler(packService, submissionService, store, cfg)
+// Init registers the API routes and the services' background jobs with
+// queue. Workers should be started after Init returns.
+func Init(r *gin.Engine, store storage.Storage, queue *jobs.Queue, cfg *config.Config) {
+	packService := samplepack.NewService(cfg, store)
+	submissionService := submission.NewService(cfg, packService, store)
+	previewService := preview.NewService(store)
+	uploadService := upload.NewService(store)
+	quotaService := quota.NewService(cfg)
+	feedService := feed.NewService(cfg, packService, submissionService)
+	webhookService := webhook.NewService()
+	discordService := discord.NewService(cfg, packService, submissionService)
+	emailService := email.NewService(cfg)
+	notificationService := notification.NewService()
+	partyService := party.NewService(packService, submissionService)
+	userService := user.NewService()
+	bus := events.NewBus()
+	handler := NewHandler(packService, submissionService, previewService, uploadService, quotaService, feedService, webhookService, discordService, emailService, notificationService, partyService, userService, bus, queue, store, cfg)
+
+	packService.RegisterJobs(queue)
+	submissionService.RegisterJobs(queue)
+	previewService.RegisterJobs(queue)
+	uploadService.RegisterJobs(queue)
+	webhookService.RegisterJobs(queue)
+	discordService.RegisterJobs(queue)
+	emailService.RegisterJobs(queue)
+	notificationService.RegisterJobs(queue)
+
+	packService.SetEventBus(bus)
+	packService.SetQuotaService(quotaService)
+	submissionService.SetEventBus(bus)
+	submissionService.SetQuotaService(quotaService)
+	webhookService.Subscribe(bus)
+	discordService.Subscribe(bus)
+	emailService.Subscribe(bus)
+	notificationService.Subscribe(bus)
+
+	if discordService.Enabled() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := discordService.RegisterCommands(ctx); err != nil {
+				log.Printf("Failed to register Discord commands: %v", err)
+			}
+		}()
+	}
+
+	gcService := gc.NewService(cfg, store)
+	gcService.RegisterJobs(queue)
+	go gcService.RunPeriodicCheck(cfg.GCInterval)
+
+	// Queue pack archive builds as soon as upload windows close
+	go packService.RunArchiveBuilder(time.Minute)
+
+	// Announce upload and submission window changes
+	go packService.RunWindowWatcher(time.Minute)
+	go packService.RunCountdown()
+	go emailService.RunDigestScheduler(time.Hour)
 
 	// Initialize routes
 	api := r.Group("/api")
@@ -52,14 +154,35 @@ func Init(r *gin.Engine, store storage.Storage, cfg *config.Config) {
 	// Auth routes
 	auth := api.Group("/auth")
 	{
-		auth.GET("/current-user", middleware.Auth(), func(c *gin.Context) {
-			userID := uint(c.GetInt("user_id"))
-			email := c.GetString("email")
-			c.JSON(http.StatusOK, gin.H{
-				"ID":    userID,
-				"email": email,
-			})
-		})
+		auth.GET("/current-user", middleware.Auth(), handler.getCurrentUser)
+	}
+
+	// Current user routes
+	me := api.Group("/me", middleware.Auth())
+	{
+		me.GET("", handler.getCurrentUser)
+		me.PATCH("", handler.updateCurrentUser)
+		me.GET("/usage", handler.getUsage)
+		me.GET("/feed-token", handler.getFeedToken)
+		me.POST("/feed-token/rotate", handler.rotateFeedToken)
+		me.GET("/email-preferences", handler.getEmailPreferences)
+		me.PUT("/email-preferences", handler.updateEmailPreferences)
+	}
+
+	// Public user profiles and contribution history
+	users := api.Group("/users", middleware.OptionalAuth())
+	{
+		users.GET("/:id", handler.getUserProfile)
+		users.GET("/:id/samples", handler.listUserSamples)
+		users.GET("/:id/submissions", handler.listUserSubmissions)
+	}
+
+	// In-app notifications of the current user
+	notifications := api.Group("/notifications", middleware.Auth())
+	{
+		notifications.GET("", handler.listNotifications)
+		notifications.POST("/read-all", handler.markAllNotificationsRead)
+		notifications.POST("/:id/read", handler.markNotificationRead)
 	}
 
 	// Admin routes for pack management
@@ -67,15 +190,44 @@ func Init(r *gin.Engine, store storage.Storage, cfg *config.Config) {
 	{
 		admin.POST("/packs", middleware.Auth(), middleware.RequireAdmin(), handler.createNewPack)
 		admin.POST("/packs/:id/close", middleware.Auth(), middleware.RequireAdmin(), handler.closePack)
+		admin.PUT("/packs/:id/loudness-limits", middleware.Auth(), middleware.RequireAdmin(), handler.setLoudnessLimits)
+		admin.PUT("/packs/:id/quota", middleware.Auth(), middleware.RequireAdmin(), handler.setPackQuota)
+		admin.PUT("/packs/:id/anonymity", middleware.Auth(), middleware.RequireAdmin(), handler.setPackAnonymity)
+		admin.DELETE("/packs/:id/samples/:sampleId", middleware.Auth(), middleware.RequireAdmin(), handler.removeSample)
+
+		admin.GET("/jobs", middleware.Auth(), middleware.RequireAdmin(), handler.listJobs)
+		admin.GET("/jobs/stats", middleware.Auth(), middleware.RequireAdmin(), handler.getJobStats)
+		admin.GET("/jobs/:id", middleware.Auth(), middleware.RequireAdmin(), handler.getJob)
+		admin.POST("/jobs/:id/retry", middleware.Auth(), middleware.RequireAdmin(), handler.retryJob)
+
+		admin.GET("/users/:id/quota", middleware.Auth(), middleware.RequireAdmin(), handler.getUserQuota)
+		admin.PUT("/users/:id/quota", middleware.Auth(), middleware.RequireAdmin(), handler.setUserQuota)
+		admin.DELETE("/users/:id/quota", middleware.Auth(), middleware.RequireAdmin(), handler.deleteUserQuota)
+
+		admin.GET("/webhooks", middleware.Auth(), middleware.RequireAdmin(), handler.listWebhooks)
+		admin.POST("/webhooks", middleware.Auth(), middleware.RequireAdmin(), handler.createWebhook)
+		admin.GET("/webhooks/:id", middleware.Auth(), middleware.RequireAdmin(), handler.getWebhook)
+		admin.PATCH("/webhooks/:id", middleware.Auth(), middleware.RequireAdmin(), handler.updateWebhook)
+		admin.DELETE("/webhooks/:id", middleware.Auth(), middleware.RequireAdmin(), handler.deleteWebhook)
+		admin.POST("/webhooks/:id/rotate-secret", middleware.Auth(), middleware.RequireAdmin(), handler.rotateWebhookSecret)
+		admin.POST("/webhooks/:id/ping", middleware.Auth(), middleware.RequireAdmin(), handler.pingWebhook)
+		admin.GET("/webhooks/:id/deliveries", middleware.Auth(), middleware.RequireAdmin(), handler.listWebhookDeliveries)
+		admin.GET("/webhook-deliveries/:id", middleware.Auth(), middleware.RequireAdmin(), handler.getWebhookDelivery)
+		admin.POST("/webhook-deliveries/:id/redeliver", middleware.Auth(), middleware.RequireAdmin(), handler.redeliverWebhook)
 	}
 
 	// Sample pack routes
 	packs := api.Group("/samples/packs")
 	{
 		packs.GET("", handler.listPacks)
-		packs.GET("/:id", handler.getPack)
+		packs.GET("/:id", middleware.OptionalAuth(), handler.getPack)
+		packs.GET("/:id/samples", middleware.OptionalAuth(), handler.listSamples)
+		packs.PATCH("/:id/samples/:sampleId", middleware.Auth(), handler.updateSample)
+		packs.GET("/:id/samples/:sampleId/download", middleware.Auth(), handler.downloadSample)
+		packs.GET("/:id/samples/:sampleId/preview", middleware.OptionalAuth(), handler.previewSample)
 		packs.POST("/:id/upload", middleware.Auth(), middleware.ValidateFileUpload(), handler.uploadSample)
-		packs.GET("/:id/download", handler.downloadPack)
+		packs.GET("/:id/download", middleware.Auth(), handler.downloadPack)
+		packs.GET("/:id/manifest", middleware.Auth(), handler.getPackManifest)
 	}
 
 	// Submission routes
@@ -85,7 +237,88 @@ func Init(r *gin.Engine, store storage.Storage, cfg *config.Config) {
 		submissions.POST("", middleware.Auth(), middleware.ValidateFileUpload(), handler.createSubmission)
 		submissions.GET("/:id", middleware.Auth(), handler.getSubmission)
 		submissions.GET("/:id/download", middleware.Auth(), handler.downloadSubmission)
+		submissions.GET("/:id/preview", middleware.Auth(), handler.previewSubmission)
+	}
+
+	// Podcast feeds, authenticated with a per-user feed token, and the
+	// public calendar of pack windows
+	feeds := api.Group("/feeds")
+	{
+		feeds.GET("/submissions.rss", handler.getGlobalFeed)
+		feeds.GET("/packs/:id/submissions.rss", handler.getPackFeed)
+		feeds.GET("/submissions/:id/audio", handler.getFeedAudio)
+		feeds.GET("/packs.ics", handler.getPackCalendar)
+	}
+
+	// Resumable uploads (tus 1.0) for samples and submissions
+	uploads := api.Group("/uploads", tusResumable())
+	{
+		uploads.OPTIONS("", handler.tusOptions)
+		uploads.POST("", middleware.Auth(), handler.createUpload)
+		uploads.HEAD("/:id", middleware.Auth(), handler.headUpload)
+		uploads.GET("/:id", middleware.Auth(), handler.getUpload)
+		uploads.PATCH("/:id", middleware.Auth(), handler.patchUpload)
+		uploads.DELETE("/:id", middleware.Auth(), handler.deleteUpload)
+	}
+
+	// Listening party rooms, synced over a WebSocket per participant
+	rooms := api.Group("/rooms")
+	{
+		rooms.GET("", middleware.Auth(), handler.listRooms)
+		rooms.POST("", middleware.Auth(), handler.createRoom)
+		rooms.GET("/:id", middleware.Auth(), handler.getRoom)
+		rooms.DELETE("/:id", middleware.Auth(), handler.closeRoom)
+		rooms.GET("/:id/ws", middleware.StreamAuth(), handler.joinRoom)
+	}
+
+	// Live pack activity as server-sent events
+	api.GET("/events/stream", middleware.StreamAuth(), handler.streamEvents)
+
+	// Unsubscribe links in notification emails, authenticated by their token
+	api.GET("/email/unsubscribe", handler.confirmUnsubscribe)
+	api.POST("/email/unsubscribe", handler.unsubscribe)
+
+	// Discord bot slash commands, signed by Discord
+	api.POST("/discord/interactions", handler.discordInteraction)
+}
+
+func (h *Handler) getCurrentUser(c *gin.Context) {
+	userID := uint(c.GetInt("user_id"))
+
+	u, err := h.userService.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+	if u == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	h.writeCurrentUser(c, u)
+}
+
+// writeCurrentUser responds with the signed-in user's own view of their
+// account, the only response that includes their email
+func (h *Handler) writeCurrentUser(c *gin.Context, u *models.User) {
+	unread, err := h.notificationService.UnreadCount(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
 	}
+
+	profile := user.NewProfile(u)
+	c.JSON(http.StatusOK, gin.H{
+		"ID":                  u.ID,
+		"email":               u.Email,
+		"name":                profile.Name,
+		"avatar":              profile.Avatar,
+		"bio":                 profile.Bio,
+		"links":               profile.Links,
+		"isAdmin":             u.IsAdmin,
+		"joinedAt":            profile.JoinedAt,
+		"unreadNotifications": unread,
+	})
 }
 
 func (h *Handler) listPacks(c *gin.Context) {
@@ -114,7 +347,7 @@ func (h *Handler) getPack(c *gin.Context) {
 		return
 	}
 
-	pack, err := h.packService.GetPack(uint(id))
+	pack, err := h.packService.GetPackFor(uint(id), viewerFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
 		return
@@ -144,28 +377,191 @@ func (h *Handler) uploadSample(c *gin.Context) {
 
 	userID := uint(c.GetInt("user_id"))
 
-	// Store the file using the storage interface
-	filePath, err := h.storage.SaveSample(file, header.Filename)
+	sample, err := newSample(uint(packID), userID, header.Filename, header.Size, c.Request.FormValue("license"), samplepack.SampleMetadata{
+		Description: c.Request.FormValue("description"),
+		Category:    c.Request.FormValue("category"),
+		Tags:        c.Request.MultipartForm.Value["tags"],
+		BPM:         c.Request.FormValue("bpm"),
+		Key:         c.Request.FormValue("key"),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
 		return
 	}
 
+	if h.storeSample(c, sample, file) {
+		c.JSON(http.StatusOK, sample)
+	}
+}
+
+// newSample builds a sample from upload fields, validating its license and
+// metadata
+func newSample(packID, userID uint, filename string, size int64, license string, meta samplepack.SampleMetadata) (*models.Sample, error) {
 	sample := &models.Sample{
-		Filename:     header.Filename,
-		FilePath:     filePath,
-		FileSize:     header.Size,
+		Filename:     filename,
+		FileSize:     size,
+		License:      models.DefaultLicense,
 		UserID:       userID,
-		SamplePackID: uint(packID),
+		SamplePackID: packID,
+	}
+
+	if license != "" {
+		sample.License = models.License(license)
+		if !sample.License.Valid() {
+			return nil, errors.NewValidationError("license", "Invalid license. Allowed licenses: CC0, CC-BY, CC-BY-NC, pack-only")
+		}
 	}
 
-	if err := h.packService.AddSample(uint(packID), sample); err != nil {
+	if err := samplepack.ApplySampleMetadata(sample, meta); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// storeSample saves an uploaded sample file, probes it and adds it to its
+// pack; its loudness is measured later by the analysis job. It reports
+// whether the sample was added, writing the error response when it was not;
+// the caller responds on success.
+func (h *Handler) storeSample(c *gin.Context, sample *models.Sample, file io.Reader) bool {
+	if err := h.quotaService.CheckSample(sample.UserID, sample.SamplePackID, sample.FileSize); err != nil {
+		quotaErrorResponse(c, err)
+		return false
+	}
+
+	// Store the file under a name of its own; Filename keeps the client's
+	name, err := storage.UniqueName(sample.Filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	stored, err := h.storage.SaveSample(file, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	filePath := stored.Path
+	sample.FilePath = stored.Path
+	sample.FileSize = stored.Size
+	sample.SHA256 = stored.SHA256
+
+	localPath, release, err := h.storage.LocalPath(filePath)
+	if err != nil {
+		h.storage.Delete(filePath) // Clean up on error
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	defer release()
+
+	if info, err := audio.Probe(localPath); err == nil {
+		sample.Format = info.Format
+		sample.SampleRate = info.SampleRate
+		sample.BitDepth = info.BitDepth
+		sample.Channels = info.Channels
+		sample.Duration = info.Duration
+	} else {
+		log.Printf("Failed to probe sample %s: %v", sample.Filename, err)
+	}
+
+	sample.PreviewStatus = models.PreviewPending
+
+	if err := h.packService.AddSample(sample.SamplePackID, sample); err != nil {
 		h.storage.Delete(filePath) // Clean up on error
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": validationMessage(err)})
+			return false
+		}
+		if errors.IsAuthorizationError(err) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return false
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add sample"})
+		return false
+	}
+
+	h.previewService.QueueSamplePreview(sample.ID)
+
+	sample.FileURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/download", sample.SamplePackID, sample.ID)
+	sample.PreviewURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/preview", sample.SamplePackID, sample.ID)
+	return true
+}
+
+func (h *Handler) listSamples(c *gin.Context) {
+	packID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	filter := samplepack.SampleFilter{
+		Tags:     c.QueryArray("tag"),
+		Category: c.Query("category"),
+		Key:      c.Query("key"),
+		Query:    c.Query("q"),
+	}
+	if value := c.Query("bpm_min"); value != "" {
+		if filter.MinBPM, err = strconv.ParseFloat(value, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bpm_min"})
+			return
+		}
+	}
+	if value := c.Query("bpm_max"); value != "" {
+		if filter.MaxBPM, err = strconv.ParseFloat(value, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bpm_max"})
+			return
+		}
+	}
+
+	samples, err := h.packService.ListSamplesFor(uint(packID), filter, viewerFrom(c))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list samples"})
+		return
+	}
+
+	c.JSON(http.StatusOK, samples)
+}
+
+func (h *Handler) updateSample(c *gin.Context) {
+	packID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	sampleID, err := strconv.ParseUint(c.Param("sampleId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sample ID"})
+		return
+	}
+
+	var req struct {
+		BPM *float64 `json:"bpm"`
+		Key *string  `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID := uint(c.GetInt("user_id"))
+	sample, err := h.packService.OverrideAnalysis(uint(packID), uint(sampleID), userID, req.BPM, req.Key)
+	if err != nil {
+		var apiErr *errors.APIError
+		if stderrors.As(err, &apiErr) {
+			c.JSON(apiErr.Code, gin.H{"error": validationMessage(err)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sample"})
 		return
 	}
 
-	sample.FileURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/download", packID, sample.ID)
 	c.JSON(http.StatusOK, sample)
 }
 
@@ -176,29 +572,127 @@ func (h *Handler) downloadPack(c *gin.Context) {
 		return
 	}
 
-	pack, err := h.packService.GetPack(uint(id))
+	if !h.checkPackDownload(c, uint(id)) {
+		return
+	}
+
+	if value := c.Query("format"); value != "" {
+		h.downloadPackVariant(c, uint(id), value)
+		return
+	}
+
+	pack, err := h.packService.GetPackArchive(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create zip file"})
+		return
+	}
+
+	h.serveArchive(c, pack.ArchivePath, pack.ArchiveHash, fmt.Sprintf("pack_%d.zip", id))
+}
+
+// downloadPackVariant serves a pack with every sample converted to a uniform
+// format such as "wav-48k-24", answering 202 while the conversion runs
+func (h *Handler) downloadPackVariant(c *gin.Context, id uint, value string) {
+	format, err := audio.ParseExportFormat(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Expected wav-<rate>-<bits>, e.g. wav-48k-24 (rates: 44.1k, 48k, 88.2k, 96k; bits: 16, 24, 32)"})
 		return
 	}
 
-	// Create temporary zip file
-	zipPath := fmt.Sprintf("/tmp/pack_%d.zip", id)
-	if err := h.packService.CreatePackZip(*pack, zipPath); err != nil {
+	variant, err := h.packService.GetPackVariant(id, format)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create zip file"})
 		return
 	}
-	defer h.storage.Delete(zipPath)
+	if variant == nil {
+		// Being converted in the background; clients retry the same URL
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusAccepted, gin.H{"status": "building", "format": format.String()})
+		return
+	}
+
+	h.serveArchive(c, variant.ArchivePath, variant.ArchiveHash, fmt.Sprintf("pack_%d_%s.zip", id, format))
+}
+
+// checkPackDownload writes an error response and returns false unless the
+// current user may download the complete pack
+func (h *Handler) checkPackDownload(c *gin.Context, id uint) bool {
+	err := h.packService.CheckPackDownload(id, viewerFrom(c))
+	switch {
+	case err == nil:
+		return true
+	case errors.IsNotFound(err):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+	default:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	}
+	return false
+}
 
+// serveArchive serves a cached zip with its content hash as ETag
+func (h *Handler) serveArchive(c *gin.Context, path, hash, filename string) {
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=pack_%d.zip", id))
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Expires", "0")
-	c.Header("Cache-Control", "must-revalidate")
-	c.Header("Pragma", "public")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("ETag", fmt.Sprintf("\"%s\"", hash))
+	c.Header("Cache-Control", "public, no-cache")
+	h.serveFile(c, path, filename, "application/zip")
+}
+
+func (h *Handler) getPackManifest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	if !h.checkPackDownload(c, uint(id)) {
+		return
+	}
 
-	c.File(zipPath)
+	manifest, err := h.packService.GetPackManifest(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load manifest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, manifest)
+}
+
+func (h *Handler) removeSample(c *gin.Context) {
+	packID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	sampleID, err := strconv.ParseUint(c.Param("sampleId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sample ID"})
+		return
+	}
+
+	if err := h.packService.RemoveSample(uint(packID), uint(sampleID)); err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sample not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove sample"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
 
 func (h *Handler) listSubmissions(c *gin.Context) {
@@ -214,7 +708,7 @@ func (h *Handler) listSubmissions(c *gin.Context) {
 		offset, _ = strconv.Atoi(offsetStr)
 	}
 
-	submissions, err := h.submissionService.ListSubmissions(uint(packID), limit, offset)
+	submissions, err := h.submissionService.ListSubmissionsFor(uint(packID), limit, offset, viewerFrom(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list submissions"})
 		return
@@ -241,32 +735,81 @@ func (h *Handler) createSubmission(c *gin.Context) {
 
 	userID := uint(c.GetInt("user_id"))
 
-	// Store the file using the storage interface
-	filePath, err := h.storage.SaveSubmission(file, header.Filename)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
-		return
-	}
-
-	// Create submission record
 	submission := &models.Submission{
 		Title:        c.Request.FormValue("title"),
 		Filename:     header.Filename,
-		FilePath:     filePath,
 		FileSize:     header.Size,
 		UserID:       userID,
 		SamplePackID: uint(packID),
-		SubmittedAt:  time.Now(),
 	}
 
-	if err := h.submissionService.CreateSubmission(userID, submission); err != nil {
+	if h.storeSubmission(c, submission, file) {
+		c.JSON(http.StatusCreated, submission)
+	}
+}
+
+// storeSubmission saves an uploaded submission file, probes it and creates
+// the submission; its loudness is measured later by the analysis job. It
+// reports whether it was created, writing the error response when it was
+// not; the caller responds on success.
+func (h *Handler) storeSubmission(c *gin.Context, submission *models.Submission, file io.Reader) bool {
+	if err := h.quotaService.CheckSubmission(submission.UserID, submission.FileSize); err != nil {
+		quotaErrorResponse(c, err)
+		return false
+	}
+
+	// Store the file under a name of its own; Filename keeps the client's
+	name, err := storage.UniqueName(submission.Filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	stored, err := h.storage.SaveSubmission(file, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	filePath := stored.Path
+	submission.FilePath = stored.Path
+	submission.FileSize = stored.Size
+	submission.SHA256 = stored.SHA256
+	submission.SubmittedAt = time.Now()
+
+	localPath, release, err := h.storage.LocalPath(filePath)
+	if err != nil {
+		h.storage.Delete(filePath) // Clean up on error
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return false
+	}
+	defer release()
+
+	if info, err := audio.Probe(localPath); err == nil {
+		submission.Duration = info.Duration
+	} else {
+		log.Printf("Failed to probe submission %s: %v", submission.Filename, err)
+	}
+
+	submission.PreviewStatus = models.PreviewPending
+
+	if err := h.submissionService.CreateSubmission(submission.UserID, submission); err != nil {
 		h.storage.Delete(filePath) // Clean up on error
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": validationMessage(err)})
+			return false
+		}
+		if errors.IsAuthorizationError(err) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return false
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return false
 	}
 
+	h.previewService.QueueSubmissionPreview(submission.ID)
+
 	submission.FileURL = fmt.Sprintf("/api/submissions/%d/download", submission.ID)
-	c.JSON(http.StatusCreated, submission)
+	submission.PreviewURL = fmt.Sprintf("/api/submissions/%d/preview", submission.ID)
+	return true
 }
 
 func (h *Handler) getSubmission(c *gin.Context) {
@@ -276,7 +819,7 @@ func (h *Handler) getSubmission(c *gin.Context) {
 		return
 	}
 
-	submission, err := h.submissionService.GetSubmission(uint(id))
+	submission, err := h.submissionService.GetSubmissionFor(uint(id), viewerFrom(c))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
 		return
@@ -299,20 +842,180 @@ func (h *Handler) downloadSubmission(c *gin.Context) {
 	}
 
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Type", "application/octet-stream")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", submission.Filename))
 	c.Header("Content-Transfer-Encoding", "binary")
 	c.Header("Expires", "0")
 	c.Header("Cache-Control", "must-revalidate")
 	c.Header("Pragma", "public")
 
-	c.File(submission.FilePath)
+	h.serveVerifiedFile(c, submission.FilePath, submission.SHA256, submission.Filename, "application/octet-stream")
+}
+
+func (h *Handler) downloadSample(c *gin.Context) {
+	packID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	sampleID, err := strconv.ParseUint(c.Param("sampleId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sample ID"})
+		return
+	}
+
+	sample, err := h.packService.GetSampleFor(uint(packID), uint(sampleID), viewerFrom(c))
+	if err != nil {
+		if errors.IsAuthorizationError(err) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sample not found"})
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(sample.Filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", sample.Filename))
+	c.Header("Cache-Control", "private, no-cache")
+	h.serveVerifiedFile(c, sample.FilePath, sample.SHA256, sample.Filename, contentType)
+}
+
+// serveVerifiedFile streams a stored file whose SHA-256 is on record, setting
+// ETag and Digest headers from it. Full downloads are re-hashed first so a
+// corrupted file is never served; range requests rely on the last check.
+func (h *Handler) serveVerifiedFile(c *gin.Context, path, sum, name, contentType string) {
+	f, err := h.storage.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	if sum != "" {
+		if c.GetHeader("Range") == "" {
+			actual, err := storage.Checksum(f)
+			if err == nil {
+				_, err = f.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+				return
+			}
+			if actual != sum {
+				log.Printf("File %s failed integrity check: expected %s, got %s", path, sum, actual)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "File failed integrity check"})
+				return
+			}
+		}
+
+		if raw, err := hex.DecodeString(sum); err == nil {
+			c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(raw))
+		}
+		c.Header("ETag", fmt.Sprintf("\"%s\"", sum))
+	}
+
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, f)
+}
+
+func (h *Handler) previewSample(c *gin.Context) {
+	packID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	sampleID, err := strconv.ParseUint(c.Param("sampleId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sample ID"})
+		return
+	}
+
+	sample, err := h.packService.GetSampleFor(uint(packID), uint(sampleID), viewerFrom(c))
+	if err != nil {
+		if errors.IsAuthorizationError(err) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sample not found"})
+		return
+	}
+
+	h.servePreview(c, sample.Preview, sample.FilePath, sample.Filename)
+}
+
+func (h *Handler) previewSubmission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+		return
+	}
+
+	submission, err := h.submissionService.GetSubmission(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	}
+
+	h.servePreview(c, submission.Preview, submission.FilePath, submission.Filename)
+}
+
+// servePreview serves the preview rendition of a file, falling back to the
+// original while the preview is pending or failed
+func (h *Handler) servePreview(c *gin.Context, p models.Preview, originalPath, originalName string) {
+	status := p.PreviewStatus
+	if status == "" {
+		status = models.PreviewPending
+	}
+	c.Header("X-Preview-Status", status)
+	c.Header("Cache-Control", "private, no-cache")
+
+	if p.PreviewStatus == models.PreviewReady {
+		h.serveFile(c, p.PreviewPath, strings.TrimSuffix(originalName, filepath.Ext(originalName))+audio.PreviewExtension, audio.PreviewContentType)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(originalName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.serveFile(c, originalPath, originalName, contentType)
+}
+
+// serveFile streams a stored file with Range and conditional request support
+func (h *Handler) serveFile(c *gin.Context, path, name, contentType string) {
+	f, err := h.storage.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	// Decrypted files have no modification time of their own
+	var modTime time.Time
+	if file, ok := f.(*os.File); ok {
+		if info, err := file.Stat(); err == nil {
+			modTime = info.ModTime()
+		}
+	}
+
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, name, modTime, f)
 }
 
 func (h *Handler) createNewPack(c *gin.Context) {
 	var req struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title       string       `json:"title"`
+		Description string       `json:"description"`
+		ArtworkURL  string       `json:"artworkUrl"`
+		UploadQuota models.Quota `json:"uploadQuota"`
+		models.LoudnessLimits
+		models.Anonymity
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -328,33 +1031,109 @@ func (h *Handler) createNewPack(c *gin.Context) {
 
 	pack.Title = req.Title
 	pack.Description = req.Description
+	pack.ArtworkURL = req.ArtworkURL
+	pack.LoudnessLimits = req.LoudnessLimits
+	pack.UploadQuota = req.UploadQuota
+	pack.Anonymity = req.Anonymity
 
 	if err := db.GetDB().Save(pack).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save pack"})
 		return
 	}
+	h.packService.PublishPackCreated(pack)
 
 	c.JSON(http.StatusCreated, pack)
 }
 
-func (h *Handler) closePack(c *gin.Context) {
+func (h *Handler) setLoudnessLimits(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
 		return
 	}
 
-	pack, err := h.packService.GetPack(uint(id))
+	var limits models.LoudnessLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	pack, err := h.packService.SetLoudnessLimits(uint(id), limits)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update loudness limits"})
 		return
 	}
 
-	pack.IsActive = false
-	if err := db.GetDB().Save(pack).Error; err != nil {
+	c.JSON(http.StatusOK, pack)
+}
+
+func (h *Handler) setPackAnonymity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	var anonymity models.Anonymity
+	if err := c.ShouldBindJSON(&anonymity); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	pack, err := h.packService.SetAnonymity(uint(id), anonymity)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update anonymity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
+
+func (h *Handler) closePack(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	pack, err := h.packService.ClosePack(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close pack"})
 		return
 	}
 
 	c.JSON(http.StatusOK, pack)
 }
+
+// viewerFrom identifies the user making the request, if any
+func viewerFrom(c *gin.Context) samplepack.Viewer {
+	viewer := samplepack.Viewer{UserID: uint(c.GetInt("user_id"))}
+	if viewer.UserID != 0 {
+		var user models.User
+		if err := db.GetDB().Select("is_admin").First(&user, viewer.UserID).Error; err == nil {
+			viewer.IsAdmin = user.IsAdmin
+		}
+	}
+	return viewer
+}
+
+// validationMessage returns the user-facing detail of a validation error
+func validationMessage(err error) string {
+	var apiErr *errors.APIError
+	if stderrors.As(err, &apiErr) && apiErr.Detail != "" {
+		return apiErr.Detail
+	}
+	return err.Error()
+}
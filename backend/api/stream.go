@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/services/samplepack"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamBuffer is how many events may wait for a slow client before
+	// newer ones are dropped
+	streamBuffer = 64
+
+	// streamHeartbeat keeps idle connections from being closed by proxies
+	streamHeartbeat = 25 * time.Second
+)
+
+// streamEvents pushes pack activity as server-sent events: uploads,
+// submissions, window changes and countdown ticks. ?pack= limits the
+// stream to one pack.
+func (h *Handler) streamEvents(c *gin.Context) {
+	var packID uint
+	if raw := c.Query("pack"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+			return
+		}
+		packID = uint(id)
+	}
+
+	listener, cancel := h.events.Listen(streamBuffer)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering in nginx
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+
+	// Start with the current countdown rather than waiting for a tick
+	if countdown, err := h.packService.CurrentCountdown(); err == nil && countdown != nil {
+		if packID == 0 || countdown.PackID == packID {
+			writeStreamEvent(c, events.PackCountdown, countdown)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			data, pack := streamData(event)
+			if data == nil || (packID != 0 && pack != packID) {
+				continue
+			}
+			writeStreamEvent(c, event.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// streamData returns what stream clients may see of an event and the pack
+// it belongs to. Samples stay private until their pack opens, so only that
+// an upload happened is passed on, and rejections only concern the uploader.
+func streamData(event events.Event) (interface{}, uint) {
+	switch data := event.Data.(type) {
+	case events.PackData:
+		return data, data.ID
+	case events.SampleData:
+		return gin.H{"id": data.ID, "packId": data.PackID}, data.PackID
+	case events.SubmissionData:
+		return data, data.PackID
+	case *samplepack.Countdown:
+		return data, data.PackID
+	case events.UploadRejectedData:
+		return nil, data.PackID
+	}
+	return event.Data, 0
+}
+
+func writeStreamEvent(c *gin.Context, eventType string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", eventType, payload)
+}
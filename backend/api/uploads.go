@@ -0,0 +1,351 @@
+package api
+
+import (
+	"encoding/base64"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/middleware"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/services/upload"
+	"sample-exchange/backend/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tus 1.0 resumable uploads, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,termination,expiration"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// tusResumable sets the protocol version on every response and rejects tus
+// requests speaking another version. GET is not part of the protocol.
+func tusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		method := c.Request.Method
+		if method != http.MethodOptions && method != http.MethodGet && c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (h *Handler) tusOptions(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.Itoa(middleware.MaxFileSize))
+	c.Status(http.StatusNoContent)
+}
+
+// createUpload starts a resumable upload. The Upload-Metadata header carries
+// the same fields as the multipart endpoints: filename, type (sample or
+// submission), packId, and for samples license, description, category,
+// tags, bpm and key, or for submissions title.
+func (h *Handler) createUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata"})
+		return
+	}
+
+	if metadata["filename"] == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing filename in Upload-Metadata"})
+		return
+	}
+	filename, ok := uploadFilename(metadata["filename"])
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filename in Upload-Metadata"})
+		return
+	}
+	if err := middleware.CheckUploadFile(filename, length); err != nil {
+		status := http.StatusBadRequest
+		if err == middleware.ErrFileTooLarge {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	packID, err := strconv.ParseUint(metadata["packId"], 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pack ID"})
+		return
+	}
+
+	userID := uint(c.GetInt("user_id"))
+
+	kind := metadata["type"]
+	switch kind {
+	case "", models.UploadKindSample:
+		kind = models.UploadKindSample
+
+		// Reject uploads that would fail once complete before any data is sent
+		if !h.packService.IsUploadAllowedForPack(uint(packID)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Upload window is closed"})
+			return
+		}
+		if _, err := sampleFromMetadata(uint(packID), userID, filename, length, metadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			return
+		}
+		if err := h.quotaService.CheckSample(userID, uint(packID), length); err != nil {
+			quotaErrorResponse(c, err)
+			return
+		}
+	case models.UploadKindSubmission:
+		if err := h.quotaService.CheckSubmission(userID, length); err != nil {
+			quotaErrorResponse(c, err)
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload type. Allowed types: sample, submission"})
+		return
+	}
+
+	u, err := h.uploadService.Create(userID, kind, uint(packID), filename, length, metadata)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/uploads/%s", u.ID))
+	c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+func (h *Handler) headUpload(c *gin.Context) {
+	u, err := h.uploadService.Get(c.Param("id"), uint(c.GetInt("user_id")))
+	if err != nil {
+		c.Status(uploadErrorStatus(err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// getUpload reports an upload's progress and, once complete, the sample or
+// submission it produced
+func (h *Handler) getUpload(c *gin.Context) {
+	u, err := h.uploadService.Get(c.Param("id"), uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": "Upload not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
+
+// patchUpload appends a chunk. The request that completes the upload also
+// stores the file. Like every successful PATCH it answers 204, with the ID
+// of the created sample or submission in X-Sample-ID or X-Submission-ID;
+// GET /uploads/:id reports it too.
+func (h *Handler) patchUpload(c *gin.Context) {
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + tusContentType})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset"})
+		return
+	}
+
+	u, err := h.uploadService.Get(c.Param("id"), uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": "Upload not found"})
+		return
+	}
+	if u.CompletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+	if offset != u.Offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+		return
+	}
+
+	if err := h.uploadService.Append(u, offset, c.Request.Body); err != nil {
+		switch {
+		case stderrors.Is(err, storage.ErrUploadOffset), stderrors.Is(err, upload.ErrUploadCompleted):
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		}
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Header("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	if u.Offset < u.Length {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	h.finishUpload(c, u)
+}
+
+// finishUpload hands a complete upload to the same path as the multipart
+// endpoints. Uploads rejected by validation are discarded; ones that fail
+// for internal reasons can be retried with an empty PATCH.
+func (h *Handler) finishUpload(c *gin.Context, u *models.Upload) {
+	claimed, err := h.uploadService.Claim(u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete upload"})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is already complete"})
+		return
+	}
+
+	file, err := h.uploadService.Open(u)
+	if err != nil {
+		h.uploadService.Release(u)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer file.Close()
+
+	metadata := upload.Metadata(u)
+
+	var ok bool
+	var sampleID, submissionID *uint
+	switch u.Kind {
+	case models.UploadKindSample:
+		if !h.packService.IsUploadAllowedForPack(u.SamplePackID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Upload window is closed"})
+			break
+		}
+		sample, err := sampleFromMetadata(u.SamplePackID, u.UserID, u.Filename, u.Length, metadata)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			break
+		}
+		if ok = h.storeSample(c, sample, file); ok {
+			sampleID = &sample.ID
+			c.Header("X-Sample-ID", strconv.FormatUint(uint64(sample.ID), 10))
+		}
+	case models.UploadKindSubmission:
+		submission := &models.Submission{
+			Title:        metadata["title"],
+			Filename:     u.Filename,
+			FileSize:     u.Length,
+			UserID:       u.UserID,
+			SamplePackID: u.SamplePackID,
+		}
+		if ok = h.storeSubmission(c, submission, file); ok {
+			submissionID = &submission.ID
+			c.Header("X-Submission-ID", strconv.FormatUint(uint64(submission.ID), 10))
+		}
+	}
+
+	switch {
+	case ok:
+		if err := h.uploadService.Complete(u, sampleID, submissionID); err != nil {
+			c.Error(err)
+		}
+		c.Status(http.StatusNoContent)
+	case c.Writer.Status() >= http.StatusInternalServerError:
+		h.uploadService.Release(u)
+	default:
+		h.uploadService.Delete(u)
+	}
+}
+
+func (h *Handler) deleteUpload(c *gin.Context) {
+	u, err := h.uploadService.Get(c.Param("id"), uint(c.GetInt("user_id")))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": "Upload not found"})
+		return
+	}
+
+	if err := h.uploadService.Delete(u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// sampleFromMetadata builds a sample from the fields of a resumable upload
+func sampleFromMetadata(packID, userID uint, filename string, size int64, metadata map[string]string) (*models.Sample, error) {
+	var tags []string
+	if metadata["tags"] != "" {
+		tags = []string{metadata["tags"]}
+	}
+
+	return newSample(packID, userID, filename, size, metadata["license"], samplepack.SampleMetadata{
+		Description: metadata["description"],
+		Category:    metadata["category"],
+		Tags:        tags,
+		BPM:         metadata["bpm"],
+		Key:         metadata["key"],
+	})
+}
+
+// uploadFilename reduces a client supplied filename to its last element, as
+// the multipart parser does for form uploads, and reports whether anything
+// usable is left
+func uploadFilename(name string) (string, bool) {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || strings.TrimSpace(name) == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma separated
+// pairs of a key and an optional base64 encoded value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid metadata pair %q", pair)
+		}
+
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+func uploadErrorStatus(err error) int {
+	if errors.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDeliveriesPageSize = 100
+
+// webhookWithSecret exposes the signing secret, which is only returned when a
+// webhook is created or its secret rotated
+type webhookWithSecret struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (h *Handler) listWebhooks(c *gin.Context) {
+	hooks, err := h.webhookService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *Handler) createWebhook(c *gin.Context) {
+	var input webhook.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	hook, err := h.webhookService.Create(input)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookWithSecret{Webhook: hook, Secret: hook.Secret})
+}
+
+func (h *Handler) getWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	hook, err := h.webhookService.Get(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func (h *Handler) updateWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var input webhook.WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	hook, err := h.webhookService.Update(uint(id), input)
+	if err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		case errors.IsValidationError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationMessage(err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if err := h.webhookService.Delete(uint(id)); err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) rotateWebhookSecret(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	hook, err := h.webhookService.RotateSecret(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookWithSecret{Webhook: hook, Secret: hook.Secret})
+}
+
+func (h *Handler) pingWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ping webhook"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func (h *Handler) listWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > maxDeliveriesPageSize {
+		limit = maxDeliveriesPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(uint(id), c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (h *Handler) getWebhookDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(uint(id))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func (h *Handler) redeliverWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(uint(id))
+	if err != nil {
+		switch {
+		case errors.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		case errors.IsValidationError(err):
+			c.JSON(http.StatusConflict, gin.H{"error": validationMessage(err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
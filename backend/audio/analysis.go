@@ -0,0 +1,208 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	analysisRate      = 11025
+	analysisFrameSize = 2048
+	analysisHop       = 256
+	maxAnalysisLength = 120 // seconds
+
+	minTempoDuration = 4.0 // seconds of audio needed to estimate tempo
+	minTempo         = 60.0
+	maxTempo         = 200.0
+)
+
+// Analysis holds the estimated tempo and key of a piece of audio. Confidence
+// values range from 0 to 1; a zero confidence means no estimate was made.
+type Analysis struct {
+	BPM           float64 `json:"bpm"`
+	BPMConfidence float64 `json:"bpmConfidence"`
+	Key           string  `json:"key"`
+	KeyConfidence float64 `json:"keyConfidence"`
+}
+
+// Analyze estimates the tempo and key of buf
+func Analyze(buf *Buffer) *Analysis {
+	samples := buf.Mono()
+	if limit := maxAnalysisLength * buf.SampleRate; len(samples) > limit {
+		samples = samples[:limit]
+	}
+	samples = resample(samples, buf.SampleRate, analysisRate)
+	rate := buf.SampleRate
+	if rate > analysisRate {
+		rate = analysisRate
+	}
+
+	spec := spectrogram(samples, analysisFrameSize, analysisHop)
+	analysis := &Analysis{}
+	if len(spec) == 0 {
+		return analysis
+	}
+
+	if float64(len(samples))/float64(rate) >= minTempoDuration {
+		analysis.BPM, analysis.BPMConfidence = estimateTempo(spec, float64(rate)/analysisHop)
+	}
+	analysis.Key, analysis.KeyConfidence = estimateKey(spec, float64(rate))
+	return analysis
+}
+
+// estimateTempo autocorrelates the spectral flux onset envelope and picks the
+// strongest periodicity between minTempo and maxTempo, weighted towards
+// 120 BPM to avoid octave errors.
+func estimateTempo(spec [][]float64, frameRate float64) (float64, float64) {
+	onsets := make([]float64, len(spec))
+	for t := 1; t < len(spec); t++ {
+		var flux float64
+		for k := range spec[t] {
+			if d := math.Log1p(spec[t][k]) - math.Log1p(spec[t-1][k]); d > 0 {
+				flux += d
+			}
+		}
+		onsets[t] = flux
+	}
+
+	var mean float64
+	for _, v := range onsets {
+		mean += v
+	}
+	mean /= float64(len(onsets))
+	for i := range onsets {
+		onsets[i] -= mean
+	}
+
+	autocorr := func(lag int) float64 {
+		var sum float64
+		for i := lag; i < len(onsets); i++ {
+			sum += onsets[i] * onsets[i-lag]
+		}
+		return sum / float64(len(onsets)-lag)
+	}
+
+	energy := autocorr(0)
+	if energy <= 0 {
+		return 0, 0
+	}
+
+	minLag := int(math.Floor(frameRate * 60 / maxTempo))
+	maxLag := int(math.Ceil(frameRate * 60 / minTempo))
+	if maxLag >= len(onsets)/2 {
+		maxLag = len(onsets)/2 - 1
+	}
+	if minLag < 1 || maxLag <= minLag {
+		return 0, 0
+	}
+
+	scores := make([]float64, maxLag+2)
+	bestLag, bestScore := 0, 0.0
+	for lag := minLag; lag <= maxLag+1; lag++ {
+		bpm := frameRate * 60 / float64(lag)
+		prior := math.Exp(-0.5 * math.Pow(math.Log2(bpm/120), 2))
+		scores[lag] = autocorr(lag) / energy
+		if lag <= maxLag && scores[lag]*prior > bestScore {
+			bestLag, bestScore = lag, scores[lag]*prior
+		}
+	}
+	if bestLag == 0 {
+		return 0, 0
+	}
+
+	// Refine the lag with parabolic interpolation between neighbouring scores
+	lag := float64(bestLag)
+	if bestLag > minLag {
+		a, b, c := scores[bestLag-1], scores[bestLag], scores[bestLag+1]
+		if denom := a - 2*b + c; denom != 0 {
+			lag += 0.5 * (a - c) / denom
+		}
+	}
+
+	bpm := math.Round(frameRate*60/lag*10) / 10
+	return bpm, math.Round(clamp(scores[bestLag], 0, 1)*100) / 100
+}
+
+// Krumhansl-Schmuckler key profiles
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// estimateKey builds a chromagram from the spectrogram and correlates it
+// with the major and minor key profiles in every transposition.
+func estimateKey(spec [][]float64, sampleRate float64) (string, float64) {
+	var chroma [12]float64
+	binWidth := sampleRate / analysisFrameSize
+	for _, frame := range spec {
+		for k := 1; k < len(frame); k++ {
+			freq := float64(k) * binWidth
+			if freq < 55 || freq > 5000 {
+				continue
+			}
+			midi := 69 + 12*math.Log2(freq/440)
+			pc := int(math.Round(midi)) % 12
+			chroma[pc] += frame[k] * frame[k]
+		}
+	}
+
+	var total float64
+	for _, v := range chroma {
+		total += v
+	}
+	if total == 0 {
+		return "", 0
+	}
+
+	best, second := -1.0, -1.0
+	bestKey := ""
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, minor := range []bool{false, true} {
+			profile := majorProfile
+			if minor {
+				profile = minorProfile
+			}
+			var rotated [12]float64
+			for i := range rotated {
+				rotated[i] = profile[(i-tonic+12)%12]
+			}
+			r := correlation(chroma[:], rotated[:])
+			if r > best {
+				best, second = r, best
+				bestKey = KeyName(tonic, minor)
+			} else if r > second {
+				second = r
+			}
+		}
+	}
+
+	// Confidence combines how well the best key fits with how clearly it
+	// beats the runner-up
+	confidence := clamp(best, 0, 1) * clamp((best-second)*10, 0, 1)
+	return bestKey, math.Round(confidence*100) / 100
+}
+
+func correlation(a, b []float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i]
+		meanB += b[i]
+	}
+	meanA /= float64(len(a))
+	meanB /= float64(len(b))
+
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,378 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+var ffmpegPath = "ffmpeg"
+
+// maxFormatChunkSize bounds the fmt and COMM chunks, which are read into
+// memory whole. Real ones are a few dozen bytes; the size field comes from
+// the upload and may claim up to 4 GiB.
+const maxFormatChunkSize = 64 << 10
+
+// Sample rates outside this range are taken to be corrupt headers
+const (
+	minSampleRate = 8000
+	maxSampleRate = 768000
+)
+
+// SetFFmpegPath sets the ffmpeg binary used to decode compressed formats
+func SetFFmpegPath(path string) {
+	ffmpegPath = path
+}
+
+// Buffer holds decoded audio as one slice of samples in [-1, 1] per channel
+type Buffer struct {
+	SampleRate int
+	Channels   [][]float32
+}
+
+// Frames returns the number of samples per channel
+func (b *Buffer) Frames() int {
+	if len(b.Channels) == 0 {
+		return 0
+	}
+	return len(b.Channels[0])
+}
+
+// Mono returns the average of all channels
+func (b *Buffer) Mono() []float32 {
+	if len(b.Channels) == 1 {
+		return b.Channels[0]
+	}
+	mono := make([]float32, b.Frames())
+	for _, ch := range b.Channels {
+		for i, v := range ch {
+			mono[i] += v
+		}
+	}
+	scale := 1 / float32(len(b.Channels))
+	for i := range mono {
+		mono[i] *= scale
+	}
+	return mono
+}
+
+// Decode reads the audio file at path into memory. WAV and AIFF are decoded
+// natively; FLAC and MP3 are decoded through ffmpeg.
+func Decode(ctx context.Context, path string) (*Buffer, error) {
+	buf := &Buffer{}
+	err := Stream(ctx, path, func(sampleRate int, block [][]float32) error {
+		if buf.Channels == nil {
+			buf.SampleRate = sampleRate
+			buf.Channels = make([][]float32, len(block))
+		}
+		for ch := range block {
+			buf.Channels[ch] = append(buf.Channels[ch], block[ch]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// streamBlockFrames is how many frames Stream passes at a time
+const streamBlockFrames = 1 << 16
+
+// BlockFunc receives decoded audio one block at a time, one slice of
+// samples per channel. The slices are reused for the next block.
+type BlockFunc func(sampleRate int, block [][]float32) error
+
+// Stream decodes the audio file at path block by block, so that files of
+// any length can be measured without holding them in memory. Decoding
+// stops at the first error fn returns, or when ctx is done.
+func Stream(ctx context.Context, path string, fn BlockFunc) error {
+	info, err := Probe(path)
+	if err != nil {
+		return err
+	}
+
+	next := fn
+	fn = func(sampleRate int, block [][]float32) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return next(sampleRate, block)
+	}
+
+	switch info.Format {
+	case "wav", "aiff":
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if info.Format == "wav" {
+			return decodeWAV(bufio.NewReader(f), fn)
+		}
+		return decodeAIFF(bufio.NewReader(f), fn)
+	default:
+		return decodeFFmpeg(ctx, path, info, fn)
+	}
+}
+
+type pcmFormat struct {
+	float     bool
+	bigEndian bool
+	bits      int
+	channels  int
+}
+
+func decodeWAV(r io.Reader, fn BlockFunc) error {
+	if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+		return err
+	}
+
+	var format pcmFormat
+	var sampleRate int
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return fmt.Errorf("wav: missing data chunk: %w", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return err
+		}
+
+		switch string(id[:]) {
+		case "fmt ":
+			if size > maxFormatChunkSize {
+				return errors.New("wav: fmt chunk too large")
+			}
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return err
+			}
+			if size < 16 {
+				return errors.New("wav: fmt chunk too short")
+			}
+			tag := binary.LittleEndian.Uint16(chunk[0:2])
+			if tag == 0xFFFE && size >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE stores the real format in the sub-format GUID
+				tag = binary.LittleEndian.Uint16(chunk[24:26])
+			}
+			if tag != 1 && tag != 3 {
+				return fmt.Errorf("wav: unsupported format tag %d", tag)
+			}
+			format = pcmFormat{
+				float:    tag == 3,
+				bits:     int(binary.LittleEndian.Uint16(chunk[14:16])),
+				channels: int(binary.LittleEndian.Uint16(chunk[2:4])),
+			}
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			if sampleRate < minSampleRate || sampleRate > maxSampleRate {
+				return fmt.Errorf("wav: implausible sample rate %d", sampleRate)
+			}
+		case "data":
+			if sampleRate == 0 {
+				return errors.New("wav: data chunk before fmt chunk")
+			}
+			return readPCM(io.LimitReader(r, int64(size)), format, sampleRate, fn)
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func decodeAIFF(r io.Reader, fn BlockFunc) error {
+	var form [12]byte
+	if _, err := io.ReadFull(r, form[:]); err != nil {
+		return err
+	}
+	aifc := string(form[8:12]) == "AIFC"
+
+	format := pcmFormat{bigEndian: true}
+	var sampleRate int
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &id); err != nil {
+			return fmt.Errorf("aiff: missing SSND chunk: %w", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return err
+		}
+
+		switch string(id[:]) {
+		case "COMM":
+			if size > maxFormatChunkSize {
+				return errors.New("aiff: COMM chunk too large")
+			}
+			chunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return err
+			}
+			if size < 18 {
+				return errors.New("aiff: COMM chunk too short")
+			}
+			format.channels = int(binary.BigEndian.Uint16(chunk[0:2]))
+			format.bits = int(binary.BigEndian.Uint16(chunk[6:8]))
+			var rate [10]byte
+			copy(rate[:], chunk[8:18])
+			sampleRate = int(extendedToFloat(rate))
+			if sampleRate < minSampleRate || sampleRate > maxSampleRate {
+				return fmt.Errorf("aiff: implausible sample rate %d", sampleRate)
+			}
+
+			if aifc && size >= 22 {
+				switch string(chunk[18:22]) {
+				case "NONE":
+				case "sowt":
+					format.bigEndian = false
+				case "fl32", "FL32":
+					format.float, format.bits = true, 32
+				case "fl64", "FL64":
+					format.float, format.bits = true, 64
+				default:
+					return fmt.Errorf("aiff: unsupported compression %q", chunk[18:22])
+				}
+			}
+		case "SSND":
+			if sampleRate == 0 {
+				return errors.New("aiff: SSND chunk before COMM chunk")
+			}
+			var offset, blockSize uint32
+			if err := binary.Read(r, binary.BigEndian, &offset); err != nil {
+				return err
+			}
+			if err := binary.Read(r, binary.BigEndian, &blockSize); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(offset)); err != nil {
+				return err
+			}
+			return readPCM(io.LimitReader(r, int64(size)-8-int64(offset)), format, sampleRate, fn)
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readPCM reads interleaved PCM frames in the given format until r is
+// exhausted, passing them to fn a block at a time
+func readPCM(r io.Reader, format pcmFormat, sampleRate int, fn BlockFunc) error {
+	if format.channels < 1 {
+		return errors.New("invalid channel count")
+	}
+	width := (format.bits + 7) / 8
+	if width < 1 || width > 8 || (format.float && width != 4 && width != 8) {
+		return fmt.Errorf("unsupported bit depth %d", format.bits)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if format.bigEndian {
+		order = binary.BigEndian
+	}
+
+	frameSize := width * format.channels
+	raw := make([]byte, streamBlockFrames*frameSize)
+	block := make([][]float32, format.channels)
+	for ch := range block {
+		block[ch] = make([]float32, streamBlockFrames)
+	}
+	for {
+		n, err := io.ReadFull(r, raw)
+		frames := n / frameSize
+		if frames > 0 {
+			for i := 0; i < frames; i++ {
+				frame := raw[i*frameSize : (i+1)*frameSize]
+				for ch := range block {
+					block[ch][i] = decodeSample(frame[ch*width:(ch+1)*width], format, order)
+				}
+			}
+			for ch := range block {
+				block[ch] = block[ch][:frames]
+			}
+			if err := fn(sampleRate, block); err != nil {
+				return err
+			}
+			for ch := range block {
+				block[ch] = block[ch][:streamBlockFrames]
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decodeSample(b []byte, format pcmFormat, order binary.ByteOrder) float32 {
+	if format.float {
+		if len(b) == 4 {
+			return math.Float32frombits(order.Uint32(b))
+		}
+		return float32(math.Float64frombits(order.Uint64(b)))
+	}
+
+	// 8-bit WAV is unsigned, everything else is signed two's complement
+	if len(b) == 1 && !format.bigEndian {
+		return float32(int(b[0])-128) / 128
+	}
+
+	var v int64
+	if format.bigEndian {
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+	} else {
+		for i := len(b) - 1; i >= 0; i-- {
+			v = v<<8 | int64(b[i])
+		}
+	}
+	shift := uint(64 - 8*len(b))
+	v = v << shift >> shift // sign extend
+	return float32(float64(v) / float64(int64(1)<<(8*len(b)-1)))
+}
+
+// decodeFFmpeg reads ffmpeg's output as it is produced, so compressed files
+// that expand to far more PCM than their size are never held in memory
+func decodeFFmpeg(ctx context.Context, path string, info *Info, fn BlockFunc) error {
+	if info.Channels < 1 || info.SampleRate < minSampleRate || info.SampleRate > maxSampleRate {
+		return ErrUnsupportedFormat
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, "-v", "error", "-nostdin", "-i", path,
+		"-ac", strconv.Itoa(info.Channels), "-ar", strconv.Itoa(info.SampleRate),
+		"-f", "f32le", "-acodec", "pcm_f32le", "-")
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	err = readPCM(stdout, pcmFormat{float: true, bits: 32, channels: info.Channels}, info.SampleRate, fn)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// fft computes an in-place radix-2 FFT. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a := x[start+k]
+				b := x[start+k+size/2] * w
+				x[start+k] = a + b
+				x[start+k+size/2] = a - b
+				w *= step
+			}
+		}
+	}
+}
+
+// spectrogram returns the magnitude spectrum of Hann-windowed frames of
+// samples, frameSize apart by hop. Only the first frameSize/2+1 bins are kept.
+func spectrogram(samples []float32, frameSize, hop int) [][]float64 {
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize))
+	}
+
+	var frames [][]float64
+	buf := make([]complex128, frameSize)
+	for start := 0; start+frameSize <= len(samples); start += hop {
+		for i := range buf {
+			buf[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		fft(buf)
+		mags := make([]float64, frameSize/2+1)
+		for i := range mags {
+			mags[i] = cmplx.Abs(buf[i])
+		}
+		frames = append(frames, mags)
+	}
+	return frames
+}
+
+// resample converts samples to a lower rate by averaging, which is enough
+// for analysis purposes.
+func resample(samples []float32, from, to int) []float32 {
+	if to >= from {
+		return samples
+	}
+	ratio := float64(from) / float64(to)
+	out := make([]float32, int(float64(len(samples))/ratio))
+	for i := range out {
+		start := int(float64(i) * ratio)
+		end := int(float64(i+1) * ratio)
+		if end > len(samples) {
+			end = len(samples)
+		}
+		var sum float32
+		for _, v := range samples[start:end] {
+			sum += v
+		}
+		out[i] = sum / float32(end-start)
+	}
+	return out
+}
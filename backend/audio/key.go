@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid musical key")
+
+var pitchClasses = map[string]int{
+	"C": 0, "B#": 0,
+	"C#": 1, "Db": 1,
+	"D":  2,
+	"D#": 3, "Eb": 3,
+	"E": 4, "Fb": 4,
+	"F": 5, "E#": 5,
+	"F#": 6, "Gb": 6,
+	"G":  7,
+	"G#": 8, "Ab": 8,
+	"A":  9,
+	"A#": 10, "Bb": 10,
+	"B": 11, "Cb": 11,
+}
+
+// Preferred spelling of each pitch class in major and minor keys
+var (
+	majorNames = [12]string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	minorNames = [12]string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "G#", "A", "Bb", "B"}
+)
+
+// KeyName returns the canonical name of the key with the given pitch class
+// (0 = C) and mode, e.g. "F# minor".
+func KeyName(pitchClass int, minor bool) string {
+	pitchClass = ((pitchClass % 12) + 12) % 12
+	if minor {
+		return minorNames[pitchClass] + " minor"
+	}
+	return majorNames[pitchClass] + " major"
+}
+
+// ParseKey normalizes a musical key such as "Am", "c# min" or "Eb major" to
+// its canonical form ("A minor", "C# minor", "Eb major"). A bare root note is
+// treated as major.
+func ParseKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", ErrInvalidKey
+	}
+
+	root := strings.ToUpper(s[:1])
+	rest := s[1:]
+	if len(rest) > 0 && (rest[0] == '#' || rest[0] == 'b') {
+		root += rest[:1]
+		rest = rest[1:]
+	} else if strings.HasPrefix(rest, "♯") {
+		root += "#"
+		rest = strings.TrimPrefix(rest, "♯")
+	} else if strings.HasPrefix(rest, "♭") {
+		root += "b"
+		rest = strings.TrimPrefix(rest, "♭")
+	}
+
+	pitchClass, ok := pitchClasses[root]
+	if !ok {
+		return "", ErrInvalidKey
+	}
+
+	mode := strings.TrimSpace(rest)
+	if mode == "M" {
+		return KeyName(pitchClass, false), nil
+	}
+	switch strings.ToLower(mode) {
+	case "", "maj", "major":
+		return KeyName(pitchClass, false), nil
+	case "m", "min", "minor":
+		return KeyName(pitchClass, true), nil
+	}
+	return "", ErrInvalidKey
+}
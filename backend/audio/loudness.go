@@ -0,0 +1,317 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+const (
+	// SilenceFloor is reported for loudness and peak levels of silent audio
+	SilenceFloor = -120.0
+
+	// ClipThreshold is the absolute sample value counted as clipped
+	ClipThreshold = 0.999
+
+	// Advisory levels above which uploads get a warning
+	WarnTruePeakDBTP = -1.0
+	WarnLoudnessLUFS = -8.0
+)
+
+// Loudness holds the EBU R128 / ITU-R BS.1770 measurements of a piece of audio
+type Loudness struct {
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeakDBTP   float64 `json:"truePeakDbtp"`
+	ClippedSamples int     `json:"clippedSamples"`
+}
+
+// Warnings returns human-readable warnings for measurements above the
+// advisory levels
+func (l *Loudness) Warnings() []string {
+	var warnings []string
+	if l.ClippedSamples > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d clipped samples detected", l.ClippedSamples))
+	}
+	if l.TruePeakDBTP > WarnTruePeakDBTP {
+		warnings = append(warnings, fmt.Sprintf("True peak of %.1f dBTP exceeds %.1f dBTP", l.TruePeakDBTP, WarnTruePeakDBTP))
+	}
+	if l.IntegratedLUFS > WarnLoudnessLUFS {
+		warnings = append(warnings, fmt.Sprintf("Integrated loudness of %.1f LUFS is above %.1f LUFS and may be heavily limited", l.IntegratedLUFS, WarnLoudnessLUFS))
+	}
+	return warnings
+}
+
+// MeasureLoudness computes integrated loudness, true peak and the number of
+// clipped samples of buf
+func MeasureLoudness(buf *Buffer) *Loudness {
+	m := newLoudnessMeter(buf.SampleRate, len(buf.Channels))
+	m.write(buf.Channels)
+	return m.result()
+}
+
+// MeasureLoudnessFile measures the audio file at path while decoding it,
+// without holding the decoded audio in memory
+func MeasureLoudnessFile(ctx context.Context, path string) (*Loudness, error) {
+	var m *loudnessMeter
+	err := Stream(ctx, path, func(sampleRate int, block [][]float32) error {
+		if m == nil {
+			m = newLoudnessMeter(sampleRate, len(block))
+		}
+		m.write(block)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return &Loudness{IntegratedLUFS: SilenceFloor, TruePeakDBTP: SilenceFloor}, nil
+	}
+	return m.result(), nil
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two-stage K-weighting filter from BS.1770 for the
+// given sample rate, using the coefficient derivation from libebur128 so it
+// is exact at rates other than 48 kHz
+func kWeighting(sampleRate float64) (*biquad, *biquad) {
+	// Stage 1: high shelf modelling the acoustic effect of the head
+	g, q, fc := 3.99984385397, 0.7071752369554193, 1681.9744509555319
+	k := math.Tan(math.Pi * fc / sampleRate)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// Stage 2: RLB high pass
+	q, fc = 0.5003270373253953, 38.13547087613982
+	k = math.Tan(math.Pi * fc / sampleRate)
+	a0 = 1 + k/q + k*k
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// truePeakTaps is the length of the windowed-sinc interpolator used to
+// oversample for the true peak, as described in BS.1770 Annex 2
+const truePeakTaps = 12
+
+// loudnessMeter measures audio written to it block by block. Integrated
+// loudness follows BS.1770-4: 400 ms blocks with 75% overlap, an absolute
+// gate at -70 LUFS and a relative gate 10 LU below the absolute-gated
+// loudness. Blocks are built from 100 ms hops, so only one number per hop
+// is kept however long the audio is.
+type loudnessMeter struct {
+	filters [][2]*biquad // K-weighting shelf and high-pass per channel
+
+	hopSize   int
+	hopFill   int
+	hopSum    float64
+	hopSums   []float64 // K-weighted energy per complete hop, over all channels
+	frames    int
+	totalSum  float64 // for audio shorter than one block
+	phases    [][truePeakTaps]float64
+	history   [][]float32 // per channel, samples awaiting true peak interpolation
+	processed []int       // per channel, how many leading history samples are context only
+	peak      float64
+	clipped   int
+}
+
+func newLoudnessMeter(sampleRate, channels int) *loudnessMeter {
+	m := &loudnessMeter{
+		filters:   make([][2]*biquad, channels),
+		hopSize:   max(sampleRate/10, 1),
+		history:   make([][]float32, channels),
+		processed: make([]int, channels),
+	}
+	for ch := range m.filters {
+		shelf, highPass := kWeighting(float64(sampleRate))
+		m.filters[ch] = [2]*biquad{shelf, highPass}
+	}
+
+	factor := 4
+	if sampleRate >= 176400 {
+		factor = 1
+	} else if sampleRate >= 88200 {
+		factor = 2
+	}
+	m.phases = make([][truePeakTaps]float64, factor)
+	for p := range m.phases {
+		offset := float64(p) / float64(factor)
+		for t := 0; t < truePeakTaps; t++ {
+			x := float64(t-truePeakTaps/2+1) - offset
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/float64(truePeakTaps/2))
+			m.phases[p][t] = sinc * window
+		}
+	}
+	return m
+}
+
+// write adds a block of audio, one slice of samples per channel
+func (m *loudnessMeter) write(block [][]float32) {
+	if len(block) == 0 {
+		return
+	}
+	frames := len(block[0])
+
+	for i := 0; i < frames; i++ {
+		var energy float64
+		for ch, samples := range block {
+			v := samples[i]
+			if v >= ClipThreshold || v <= -ClipThreshold {
+				m.clipped++
+			}
+			y := m.filters[ch][1].process(m.filters[ch][0].process(float64(v)))
+			energy += y * y
+		}
+		m.hopSum += energy
+		m.totalSum += energy
+		m.hopFill++
+		if m.hopFill == m.hopSize {
+			m.hopSums = append(m.hopSums, m.hopSum)
+			m.hopSum, m.hopFill = 0, 0
+		}
+	}
+	m.frames += frames
+
+	for ch, samples := range block {
+		m.history[ch] = append(m.history[ch], samples...)
+		m.interpolate(ch, false)
+	}
+}
+
+// interpolate finds the true peak of the samples of a channel whose
+// neighbours have all arrived, or of every remaining sample at the end
+func (m *loudnessMeter) interpolate(ch int, final bool) {
+	const before, after = truePeakTaps/2 - 1, truePeakTaps / 2
+	data := m.history[ch]
+	end := len(data)
+	if !final {
+		end -= after
+	}
+
+	for i := m.processed[ch]; i < end; i++ {
+		for p := range m.phases {
+			var y float64
+			if p == 0 {
+				y = float64(data[i])
+			} else {
+				for t := 0; t < truePeakTaps; t++ {
+					// Samples before the start or after the end are silence
+					if j := i + t - before; j >= 0 && j < len(data) {
+						y += float64(data[j]) * m.phases[p][t]
+					}
+				}
+			}
+			if y < 0 {
+				y = -y
+			}
+			if y > m.peak {
+				m.peak = y
+			}
+		}
+	}
+
+	// Keep the context the next samples need
+	if end > m.processed[ch] {
+		keep := max(end-before, 0)
+		m.history[ch] = append(data[:0:0], data[keep:]...)
+		m.processed[ch] = end - keep
+	}
+}
+
+func (m *loudnessMeter) result() *Loudness {
+	for ch := range m.history {
+		m.interpolate(ch, true)
+	}
+
+	truePeak := SilenceFloor
+	if m.peak > 0 {
+		truePeak = 20 * math.Log10(m.peak)
+	}
+	return &Loudness{
+		IntegratedLUFS: round1(m.integrated()),
+		TruePeakDBTP:   round1(truePeak),
+		ClippedSamples: m.clipped,
+	}
+}
+
+// integrated applies the BS.1770 gates to the mean square of each block
+func (m *loudnessMeter) integrated() float64 {
+	if m.frames == 0 {
+		return SilenceFloor
+	}
+
+	const hopsPerBlock = 4
+	var power []float64
+	if len(m.hopSums) < hopsPerBlock {
+		// Shorter than one block: measure everything as a single block
+		power = []float64{m.totalSum / float64(m.frames)}
+	} else {
+		blockSize := float64(hopsPerBlock * m.hopSize)
+		for j := 0; j+hopsPerBlock <= len(m.hopSums); j++ {
+			var sum float64
+			for _, v := range m.hopSums[j : j+hopsPerBlock] {
+				sum += v
+			}
+			power = append(power, sum/blockSize)
+		}
+	}
+
+	loudness := func(p float64) float64 {
+		return -0.691 + 10*math.Log10(p)
+	}
+	gatedMean := func(threshold float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, p := range power {
+			if p > 0 && loudness(p) > threshold {
+				sum += p
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return sum / float64(n), n
+	}
+
+	absolute, n := gatedMean(-70)
+	if n == 0 {
+		return SilenceFloor
+	}
+	relative, n := gatedMean(loudness(absolute) - 10)
+	if n == 0 {
+		return SilenceFloor
+	}
+	return loudness(relative)
+}
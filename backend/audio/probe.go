@@ -0,0 +1,255 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Info describes the basic properties of an audio file
+type Info struct {
+	Format     string  `json:"format"` // wav, aiff, flac or mp3
+	SampleRate int     `json:"sampleRate"`
+	BitDepth   int     `json:"bitDepth,omitempty"` // 0 for lossy formats
+	Channels   int     `json:"channels"`
+	Duration   float64 `json:"duration"` // seconds
+}
+
+// Probe reads the header of the audio file at path and returns its properties
+// without decoding any audio data.
+func Probe(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(12)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	switch {
+	case string(magic[0:4]) == "RIFF" && string(magic[8:12]) == "WAVE":
+		return probeWAV(r)
+	case string(magic[0:4]) == "FORM" && (string(magic[8:12]) == "AIFF" || string(magic[8:12]) == "AIFC"):
+		return probeAIFF(r)
+	case string(magic[0:4]) == "fLaC":
+		return probeFLAC(r)
+	case string(magic[0:3]) == "ID3" || (magic[0] == 0xFF && magic[1]&0xE0 == 0xE0):
+		return probeMP3(r, stat.Size())
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func probeWAV(r io.Reader) (*Info, error) {
+	if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+		return nil, err
+	}
+
+	info := &Info{Format: "wav"}
+	var byteRate uint32
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, fmt.Errorf("wav: missing data chunk: %w", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+
+		switch string(id[:]) {
+		case "fmt ":
+			var fmtChunk struct {
+				AudioFormat   uint16
+				Channels      uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &fmtChunk); err != nil {
+				return nil, err
+			}
+			info.Channels = int(fmtChunk.Channels)
+			info.SampleRate = int(fmtChunk.SampleRate)
+			info.BitDepth = int(fmtChunk.BitsPerSample)
+			byteRate = fmtChunk.ByteRate
+			if _, err := io.CopyN(io.Discard, r, int64(size)-16+int64(size%2)); err != nil {
+				return nil, err
+			}
+		case "data":
+			if info.SampleRate == 0 {
+				return nil, errors.New("wav: data chunk before fmt chunk")
+			}
+			if byteRate > 0 {
+				info.Duration = float64(size) / float64(byteRate)
+			}
+			return info, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func probeAIFF(r io.Reader) (*Info, error) {
+	if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+		return nil, err
+	}
+
+	for {
+		var id [4]byte
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &id); err != nil {
+			return nil, fmt.Errorf("aiff: missing COMM chunk: %w", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+
+		if string(id[:]) != "COMM" {
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var comm struct {
+			Channels   uint16
+			Frames     uint32
+			SampleSize uint16
+			SampleRate [10]byte
+		}
+		if err := binary.Read(r, binary.BigEndian, &comm); err != nil {
+			return nil, err
+		}
+		rate := extendedToFloat(comm.SampleRate)
+		info := &Info{
+			Format:     "aiff",
+			SampleRate: int(rate),
+			BitDepth:   int(comm.SampleSize),
+			Channels:   int(comm.Channels),
+		}
+		if rate > 0 {
+			info.Duration = float64(comm.Frames) / rate
+		}
+		return info, nil
+	}
+}
+
+// extendedToFloat converts an 80-bit IEEE 754 extended float, as used for
+// the AIFF sample rate, to a float64.
+func extendedToFloat(b [10]byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b[0:2]) & 0x7FFF)
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	f := float64(mantissa) * math.Pow(2, float64(exponent-16383-63))
+	if b[0]&0x80 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func probeFLAC(r io.Reader) (*Info, error) {
+	var header [4 + 4 + 34]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[4]&0x7F != 0 {
+		return nil, errors.New("flac: first metadata block is not STREAMINFO")
+	}
+
+	si := header[8:]
+	sampleRate := int(si[10])<<12 | int(si[11])<<4 | int(si[12])>>4
+	channels := int(si[12]>>1&0x07) + 1
+	bitDepth := int(si[12]&0x01)<<4 | int(si[13]>>4) + 1
+	totalSamples := uint64(si[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(si[14:18]))
+
+	info := &Info{
+		Format:     "flac",
+		SampleRate: sampleRate,
+		BitDepth:   bitDepth,
+		Channels:   channels,
+	}
+	if sampleRate > 0 {
+		info.Duration = float64(totalSamples) / float64(sampleRate)
+	}
+	return info, nil
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}, // MPEG-1 Layer III
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},     // MPEG-2/2.5 Layer III
+	}
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},  // MPEG-2.5
+		{0, 0, 0},             // reserved
+		{22050, 24000, 16000}, // MPEG-2
+		{44100, 48000, 32000}, // MPEG-1
+	}
+)
+
+func probeMP3(r *bufio.Reader, fileSize int64) (*Info, error) {
+	var offset int64
+
+	// Skip ID3v2 tag
+	if head, err := r.Peek(10); err == nil && string(head[0:3]) == "ID3" {
+		size := int64(head[6]&0x7F)<<21 | int64(head[7]&0x7F)<<14 | int64(head[8]&0x7F)<<7 | int64(head[9]&0x7F)
+		offset = 10 + size
+		if _, err := r.Discard(int(offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Scan for the first valid frame header
+	for {
+		head, err := r.Peek(4)
+		if err != nil {
+			return nil, errors.New("mp3: no frame header found")
+		}
+		if head[0] == 0xFF && head[1]&0xE0 == 0xE0 {
+			version := head[1] >> 3 & 0x03
+			bitrateIndex := head[2] >> 4
+			rateIndex := head[2] >> 2 & 0x03
+			if version != 1 && head[1]>>1&0x03 == 1 && bitrateIndex != 0 && bitrateIndex != 15 && rateIndex != 3 {
+				table := 1
+				if version == 3 {
+					table = 0
+				}
+				bitrate := mp3Bitrates[table][bitrateIndex] * 1000
+				channels := 2
+				if head[3]>>6 == 3 {
+					channels = 1
+				}
+				return &Info{
+					Format:     "mp3",
+					SampleRate: mp3SampleRates[version][rateIndex],
+					Channels:   channels,
+					Duration:   float64(fileSize-offset) * 8 / float64(bitrate),
+				}, nil
+			}
+		}
+		if _, err := r.Discard(1); err != nil {
+			return nil, err
+		}
+		offset++
+	}
+}
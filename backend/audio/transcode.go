@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	PreviewBitrate     = "96k"
+	PreviewExtension   = ".mp3"
+	PreviewContentType = "audio/mpeg"
+)
+
+// TranscodePreview encodes the audio file at src as a compact stereo MP3
+// suitable for in-browser listening and writes it to dst
+func TranscodePreview(ctx context.Context, src, dst string) error {
+	return runFFmpeg(ctx, "-i", src, "-vn", "-map_metadata", "-1",
+		"-ac", "2", "-c:a", "libmp3lame", "-b:a", PreviewBitrate,
+		"-f", "mp3", "-y", dst)
+}
+
+func runFFmpeg(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, append([]string{"-v", "error", "-nostdin"}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+var exportSampleRates = map[string]int{
+	"44k":   44100,
+	"44.1k": 44100,
+	"48k":   48000,
+	"88.2k": 88200,
+	"96k":   96000,
+}
+
+// ExportFormat is a uniform WAV format samples can be converted to
+type ExportFormat struct {
+	SampleRate int
+	BitDepth   int // 16 or 24 bit integer, or 32 bit float
+}
+
+// ParseExportFormat parses formats of the form "wav-48k-24"
+func ParseExportFormat(s string) (ExportFormat, error) {
+	parts := strings.Split(strings.ToLower(s), "-")
+	if len(parts) != 3 || parts[0] != "wav" {
+		return ExportFormat{}, ErrInvalidExportFormat
+	}
+
+	rate, ok := exportSampleRates[parts[1]]
+	if !ok {
+		return ExportFormat{}, ErrInvalidExportFormat
+	}
+
+	bits, err := strconv.Atoi(parts[2])
+	if err != nil || (bits != 16 && bits != 24 && bits != 32) {
+		return ExportFormat{}, ErrInvalidExportFormat
+	}
+
+	return ExportFormat{SampleRate: rate, BitDepth: bits}, nil
+}
+
+// String returns the canonical name of the format, e.g. "wav-44.1k-16"
+func (f ExportFormat) String() string {
+	rate := strconv.FormatFloat(float64(f.SampleRate)/1000, 'f', -1, 64)
+	return fmt.Sprintf("wav-%sk-%d", rate, f.BitDepth)
+}
+
+// Convert resamples the audio file at src and writes it to dst as a WAV in
+// format f. Reductions to 16 bit are dithered. ffmpeg is killed if ctx is
+// done first.
+func Convert(ctx context.Context, src, dst string, f ExportFormat) error {
+	codec := map[int]string{16: "pcm_s16le", 24: "pcm_s24le", 32: "pcm_f32le"}[f.BitDepth]
+	filter := fmt.Sprintf("aresample=%d", f.SampleRate)
+	if f.BitDepth == 16 {
+		filter += ":dither_method=triangular"
+	}
+	return runFFmpeg(ctx, "-i", src, "-vn", "-map_metadata", "-1",
+		"-af", filter, "-c:a", codec, "-f", "wav", "-y", dst)
+}
@@ -0,0 +1,161 @@
+// Command fakediscord stands in for Discord when developing the bot. It
+// serves the REST endpoints the bot calls, printing what it receives, and
+// sends signed slash command interactions to the backend.
+//
+// Usage:
+//
+//	fakediscord serve [-addr :9100] [-seed hex]
+//	fakediscord invoke [-url http://localhost:8080/api/discord/interactions] [-seed hex] pack current|deadline
+//	fakediscord invoke [-url ...] [-seed hex] submissions [pack-id]
+//
+// Point the backend at it with DISCORD_API_URL=http://localhost:9100/api/v10
+// and DISCORD_PUBLIC_KEY set to the key serve prints.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sample-exchange/backend/services/discord"
+)
+
+// devSeed is the default signing key seed, so serve and invoke agree
+// without configuration
+const devSeed = "66616b65646973636f72642d6465762d7369676e696e672d6b65792d30303031"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		runServe(os.Args[2:])
+	case "invoke":
+		runInvoke(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fakediscord serve [-addr :9100] | invoke [-url url] pack current|deadline | invoke [-url url] submissions [pack-id]")
+	os.Exit(2)
+}
+
+func signingKey(seed string) ed25519.PrivateKey {
+	b, err := hex.DecodeString(seed)
+	if err != nil || len(b) != ed25519.SeedSize {
+		log.Fatalf("seed must be %d hex encoded bytes", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(b)
+}
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":9100", "address to listen on")
+	seed := flags.String("seed", devSeed, "hex seed of the interaction signing key")
+	flags.Parse(args)
+
+	key := signingKey(*seed)
+	fmt.Printf("DISCORD_API_URL=http://localhost%s/api/v10\n", *addr)
+	fmt.Printf("DISCORD_PUBLIC_KEY=%s\n", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	http.HandleFunc("/api/v10/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bot ") {
+			http.Error(w, `{"message": "401: Unauthorized", "code": 0}`, http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		log.Printf("%s %s\n%s", r.Method, strings.TrimPrefix(r.URL.Path, "/api/v10"), pretty.String())
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages"):
+			fmt.Fprintf(w, `{"id": "%d"}`, time.Now().UnixNano())
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/commands"):
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "404: Not Found", "code": 0}`)
+		}
+	})
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func runInvoke(args []string) {
+	flags := flag.NewFlagSet("invoke", flag.ExitOnError)
+	url := flags.String("url", "http://localhost:8080/api/discord/interactions", "interactions endpoint of the backend")
+	seed := flags.String("seed", devSeed, "hex seed of the interaction signing key")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	data := &discord.CommandData{Name: flags.Arg(0)}
+	switch data.Name {
+	case "pack":
+		if flags.NArg() < 2 {
+			usage()
+		}
+		data.Options = []discord.CommandOption{{Type: discord.OptionSubcommand, Name: flags.Arg(1)}}
+	case "submissions":
+		if flags.NArg() > 1 {
+			if _, err := strconv.ParseUint(flags.Arg(1), 10, 64); err != nil {
+				usage()
+			}
+			data.Options = []discord.CommandOption{{Type: discord.OptionInteger, Name: "pack", Value: json.RawMessage(flags.Arg(1))}}
+		}
+	}
+
+	body, err := json.Marshal(discord.Interaction{
+		ID:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		Type:  discord.InteractionCommand,
+		Token: "fake-interaction-token",
+		Data:  data,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := ed25519.Sign(signingKey(*seed), append([]byte(timestamp), body...))
+
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(signature))
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response discord.InteractionResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&response) != nil || response.Data == nil {
+		log.Fatalf("interaction failed with status %d", resp.StatusCode)
+	}
+	fmt.Println(response.Data.Content)
+}
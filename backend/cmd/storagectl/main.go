@@ -0,0 +1,105 @@
+// Command storagectl runs maintenance tasks against the file store.
+//
+// Usage:
+//
+//	storagectl gc [-purge]       report (and optionally delete) orphaned files and expired rows
+//	storagectl scrub [-backfill] re-hash stored files and report corruption
+//	storagectl rewrap            re-wrap file keys with the current master key
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/services/gc"
+	"sample-exchange/backend/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cfg := config.LoadConfig()
+	if err := db.SetupDB(); err != nil {
+		log.Fatalf("Failed to setup database: %v", err)
+	}
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to setup storage: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "gc":
+		runGC(cfg, store, os.Args[2:])
+	case "scrub":
+		runScrub(cfg, store, os.Args[2:])
+	case "rewrap":
+		runRewrap(store)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: storagectl gc [-purge] | scrub [-backfill] | rewrap")
+	os.Exit(2)
+}
+
+func runGC(cfg *config.Config, store storage.Storage, args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	purge := flags.Bool("purge", false, "delete orphaned files and expired rows")
+	flags.Parse(args)
+
+	report, err := gc.NewService(cfg, store).Check(*purge)
+	if err != nil {
+		log.Fatalf("Storage check failed: %v", err)
+	}
+	printJSON(report)
+}
+
+func runScrub(cfg *config.Config, store storage.Storage, args []string) {
+	flags := flag.NewFlagSet("scrub", flag.ExitOnError)
+	backfill := flags.Bool("backfill", false, "record checksums for files that have none")
+	flags.Parse(args)
+
+	report, err := gc.NewService(cfg, store).Scrub(*backfill)
+	if err != nil {
+		log.Fatalf("Scrub failed: %v", err)
+	}
+	printJSON(report)
+
+	if len(report.Corrupt) > 0 || len(report.Missing) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runRewrap(store storage.Storage) {
+	encrypted, ok := store.(*storage.EncryptedStorage)
+	if !ok {
+		log.Fatalf("Rewrap requires STORAGE_ENCRYPTION_KEY to be set")
+	}
+
+	report, err := encrypted.Rewrap()
+	if err != nil {
+		log.Fatalf("Rewrap failed: %v", err)
+	}
+	printJSON(report)
+
+	if len(report.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Command webhookrecv is a local webhook receiver for development. It checks
+// the signature of each delivery and prints its payload.
+//
+// Usage:
+//
+//	webhookrecv [-addr :9000] [-secret whsec_...] [-fail]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"sample-exchange/backend/services/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", "", "signing secret of the webhook; signatures are not checked when empty")
+	fail := flag.Bool("fail", false, "answer every delivery with 500 to exercise retries")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		event := r.Header.Get(webhook.EventHeader)
+		delivery := r.Header.Get(webhook.DeliveryHeader)
+		if *secret != "" {
+			err := webhook.Verify(*secret, r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body, 5*time.Minute)
+			if err != nil {
+				log.Printf("delivery %s (%s): %v", delivery, event, err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		log.Printf("delivery %s (%s)\n%s", delivery, event, pretty.String())
+
+		if *fail {
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,10 +16,31 @@ type OAuthConfig struct {
 	RedirectURL  string
 }
 
+// SMTPConfig configures outgoing email. Email is disabled without a host.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	From     string // address notification emails are sent from
+}
+
+// DiscordBotConfig configures the optional Discord bot. It is disabled
+// without a token.
+type DiscordBotConfig struct {
+	Token         string
+	ApplicationID string
+	PublicKey     string // hex Ed25519 key Discord signs interactions with
+	ChannelID     string // where announcements are posted
+	GuildID       string // registers commands in one server instead of globally
+	APIURL        string // overridden to point at a fake API in development
+}
+
 type Config struct {
 	// Server settings
-	Port string
-	Mode string
+	Port      string
+	Mode      string
+	PublicURL string // base URL used in links that leave the app, such as feeds
 
 	// Development settings
 	DevMode           bool
@@ -33,11 +55,39 @@ type Config struct {
 	// Storage settings
 	StoragePath string
 
+	// Encryption at rest, base64 encoded 32 byte master keys. Old keys
+	// decrypt files that have not been re-wrapped since a rotation.
+	EncryptionKey     string
+	OldEncryptionKeys []string
+
+	// Audio processing settings
+	FFmpegPath string
+
+	// Background job settings
+	JobWorkers int
+
+	// Default upload quotas, -1 disables a limit
+	UserQuotaFiles int   // files across all packs
+	UserQuotaBytes int64 // bytes across all packs
+	PackQuotaFiles int   // samples per user in one pack
+	PackQuotaBytes int64 // sample bytes per user in one pack
+
+	// Storage garbage collection settings
+	GCInterval  time.Duration
+	GCRetention time.Duration // how long soft-deleted files are kept
+	GCPurge     bool          // whether the periodic check deletes what it finds
+
 	// OAuth settings
 	OAuthRedirectURL string
 	GitHub           OAuthConfig
 	Google           OAuthConfig
 	Discord          OAuthConfig
+
+	// Discord bot settings
+	DiscordBot DiscordBotConfig
+
+	// Email notification settings
+	SMTP SMTPConfig
 }
 
 func LoadConfig() *Config {
@@ -48,6 +98,7 @@ func LoadConfig() *Config {
 	cfg := &Config{
 		Port:              getEnv("PORT", "8080"),
 		Mode:              getEnv("GIN_MODE", "release"),
+		PublicURL:         strings.TrimSuffix(getEnv("PUBLIC_URL", "http://localhost:8080"), "/"),
 		DevMode:           getEnv("DEV_MODE", "false") == "true",
 		BypassTimeWindows: getEnv("BYPASS_TIME_WINDOWS", "false") == "true",
 		BypassOAuth:       getEnv("BYPASS_OAUTH", "false") == "true",
@@ -55,6 +106,17 @@ func LoadConfig() *Config {
 		AccessDuration:    getEnvDuration("JWT_ACCESS_DURATION", 15*time.Minute),
 		RefreshDuration:   getEnvDuration("JWT_REFRESH_DURATION", 168*time.Hour),
 		StoragePath:       getEnv("STORAGE_PATH", "./storage"),
+		EncryptionKey:     getEnv("STORAGE_ENCRYPTION_KEY", ""),
+		OldEncryptionKeys: getEnvList("STORAGE_OLD_ENCRYPTION_KEYS"),
+		FFmpegPath:        getEnv("FFMPEG_PATH", "ffmpeg"),
+		JobWorkers:        getEnvInt("JOB_WORKERS", 2),
+		UserQuotaFiles:    getEnvInt("QUOTA_USER_MAX_FILES", -1),
+		UserQuotaBytes:    megabytes(getEnvInt("QUOTA_USER_MAX_MB", 5120)),
+		PackQuotaFiles:    getEnvInt("QUOTA_PACK_MAX_FILES", 20),
+		PackQuotaBytes:    megabytes(getEnvInt("QUOTA_PACK_MAX_MB", 500)),
+		GCInterval:        getEnvDuration("GC_INTERVAL", 24*time.Hour),
+		GCRetention:       getEnvDuration("GC_RETENTION", 30*24*time.Hour),
+		GCPurge:           getEnvBool("GC_PURGE", false),
 		OAuthRedirectURL:  getEnv("OAUTH_REDIRECT_URL", "http://localhost:3000/auth/callback"),
 
 		// OAuth Providers
@@ -73,6 +135,23 @@ func LoadConfig() *Config {
 			ClientSecret: getEnv("OAUTH_DISCORD_CLIENT_SECRET", ""),
 			RedirectURL:  strings.Replace(getEnv("OAUTH_REDIRECT_URL", "http://localhost:3000/auth/callback"), "/callback", "/discord/callback", 1),
 		},
+
+		DiscordBot: DiscordBotConfig{
+			Token:         getEnv("DISCORD_BOT_TOKEN", ""),
+			ApplicationID: getEnv("DISCORD_APPLICATION_ID", ""),
+			PublicKey:     getEnv("DISCORD_PUBLIC_KEY", ""),
+			ChannelID:     getEnv("DISCORD_CHANNEL_ID", ""),
+			GuildID:       getEnv("DISCORD_GUILD_ID", ""),
+			APIURL:        strings.TrimSuffix(getEnv("DISCORD_API_URL", "https://discord.com/api/v10"), "/"),
+		},
+
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnvInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "quixit <noreply@quixit.us>"),
+		},
 	}
 
 	return cfg
@@ -92,6 +171,34 @@ func getEnvBool(key string, fallback bool) bool {
 	return fallback
 }
 
+// getEnvList splits a comma separated variable, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// megabytes converts a size in MB to bytes, keeping negative values as-is
+func megabytes(mb int) int64 {
+	if mb < 0 {
+		return int64(mb)
+	}
+	return int64(mb) * 1024 * 1024
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if value, ok := os.LookupEnv(key); ok {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -28,6 +28,16 @@ func SetupDB() error {
 		&models.SamplePack{},
 		&models.Sample{},
 		&models.Submission{},
+		&models.PackVariant{},
+		&models.Job{},
+		&models.Upload{},
+		&models.UserQuota{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.EmailPreferences{},
+		&models.SentEmail{},
+		&models.Notification{},
+		&models.ListeningRoom{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
@@ -93,3 +93,11 @@ func IsValidationError(err error) bool {
 	}
 	return false
 }
+
+func IsAuthorizationError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Type == TypeAuthorization
+	}
+	return false
+}
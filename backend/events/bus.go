@@ -0,0 +1,136 @@
+package events
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Event types published by the pack and submission services
+const (
+	PackCreated        = "pack.created"
+	PackUploadsClosing = "pack.uploads_closing" // upload window ends within a day
+	PackUploadsClosed  = "pack.uploads_closed"  // upload window ended
+	PackOpened         = "pack.opened"          // samples released, submissions open
+	PackClosed         = "pack.closed"          // submission window ended
+	SampleUploaded     = "sample.uploaded"
+	SubmissionCreated  = "submission.created"
+
+	// PackCountdown ticks with the time left in the current pack's window.
+	// It is transient, so it is left out of Types and never persisted or
+	// sent to webhooks.
+	PackCountdown = "pack.countdown"
+
+	// UploadRejected is published when a stored sample or submission is
+	// removed for exceeding its pack's loudness limits. It concerns only
+	// the uploader, so it is left out of Types as well.
+	UploadRejected = "upload.rejected"
+)
+
+// Types lists every event type, for validating subscriptions
+var Types = []string{
+	PackCreated,
+	PackUploadsClosing,
+	PackUploadsClosed,
+	PackOpened,
+	PackClosed,
+	SampleUploaded,
+	SubmissionCreated,
+}
+
+// Event is something that happened in the app
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Handler receives published events. Handlers run synchronously in the
+// publisher's goroutine, so slow work should be queued.
+type Handler func(Event)
+
+// Bus fans events out to in-process subscribers
+type Bus struct {
+	mu        sync.RWMutex
+	handlers  []Handler
+	listeners map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{listeners: make(map[chan Event]struct{})}
+}
+
+// Subscribe registers handler for every event published after it returns
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event to every subscriber. A nil bus drops events, so
+// services work without one.
+func (b *Bus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	event := Event{Type: eventType, Time: time.Now(), Data: data}
+	for _, handler := range handlers {
+		dispatch(handler, event)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for listener := range b.listeners {
+		select {
+		case listener <- event:
+		default: // listener fell behind
+		}
+	}
+}
+
+// Listen returns a channel receiving the events published until cancel is
+// called. Events are dropped for a listener with buffer events waiting, so
+// slow consumers such as remote clients never block publishers.
+func (b *Bus) Listen(buffer int) (events <-chan Event, cancel func()) {
+	listener := make(chan Event, buffer)
+
+	b.mu.Lock()
+	b.listeners[listener] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return listener, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.listeners, listener)
+			b.mu.Unlock()
+			close(listener)
+		})
+	}
+}
+
+// dispatch keeps a panicking subscriber from failing the publisher
+func dispatch(handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler for %s panicked: %v\n%s", event.Type, r, debug.Stack())
+		}
+	}()
+	handler(event)
+}
+
+// IsType reports whether name is a known event type
+func IsType(name string) bool {
+	for _, t := range Types {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"time"
+
+	"sample-exchange/backend/models"
+)
+
+// PackData describes a pack in event payloads
+type PackData struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	UploadStart time.Time `json:"uploadStart"`
+	UploadEnd   time.Time `json:"uploadEnd"`
+	StartDate   time.Time `json:"startDate"`
+	EndDate     time.Time `json:"endDate"`
+}
+
+func NewPackData(pack *models.SamplePack) PackData {
+	return PackData{
+		ID:          pack.ID,
+		Title:       pack.Title,
+		Description: pack.Description,
+		UploadStart: pack.UploadStart,
+		UploadEnd:   pack.UploadEnd,
+		StartDate:   pack.StartDate,
+		EndDate:     pack.EndDate,
+	}
+}
+
+// SampleData describes an uploaded sample in event payloads. Uploaders are
+// left out because samples stay private until the pack opens.
+type SampleData struct {
+	ID       uint        `json:"id"`
+	PackID   uint        `json:"packId"`
+	Filename string      `json:"filename"`
+	Category string      `json:"category,omitempty"`
+	Tags     models.Tags `json:"tags,omitempty"`
+	Duration float64     `json:"duration"`
+}
+
+func NewSampleData(sample *models.Sample) SampleData {
+	return SampleData{
+		ID:       sample.ID,
+		PackID:   sample.SamplePackID,
+		Filename: sample.Filename,
+		Category: sample.Category,
+		Tags:     sample.Tags,
+		Duration: sample.Duration,
+	}
+}
+
+// UploadRejectedData describes a sample or submission removed after upload
+type UploadRejectedData struct {
+	Kind     string `json:"kind"` // sample or submission
+	ID       uint   `json:"id"`
+	PackID   uint   `json:"packId"`
+	UserID   uint   `json:"userId"`
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// SubmissionData describes a submission in event payloads. The author is
+// only included when the caller says it may be revealed.
+type SubmissionData struct {
+	ID         uint      `json:"id"`
+	PackID     uint      `json:"packId"`
+	Title      string    `json:"title"`
+	Duration   float64   `json:"duration"`
+	UserID     uint      `json:"userId,omitempty"`
+	AuthorName string    `json:"authorName,omitempty"`
+	Submitted  time.Time `json:"submittedAt"`
+}
+
+func NewSubmissionData(submission *models.Submission, author *models.User) SubmissionData {
+	data := SubmissionData{
+		ID:        submission.ID,
+		PackID:    submission.SamplePackID,
+		Title:     submission.Title,
+		Duration:  submission.Duration,
+		Submitted: submission.SubmittedAt,
+	}
+	if author != nil {
+		data.UserID = author.ID
+		data.AuthorName = author.Name
+	}
+	return data
+}
@@ -0,0 +1,358 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = 10 * time.Minute
+
+	pollInterval = 2 * time.Second
+	lockGrace    = time.Minute // running jobs this long past their timeout are reclaimed
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = time.Hour
+)
+
+var ErrNotRegistered = stderrors.New("job type not registered")
+
+type handlerFunc func(ctx context.Context, payload []byte) error
+
+// registration is a registered job type's handler and time limit
+type registration struct {
+	handler handlerFunc
+	timeout time.Duration
+}
+
+// Queue is a persistent job queue stored in Postgres. Jobs are claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED so any number of workers and server
+// instances can share it.
+type Queue struct {
+	mu       sync.RWMutex
+	handlers map[string]registration
+	wake     chan struct{}
+	workerID string
+}
+
+func NewQueue() *Queue {
+	host, _ := os.Hostname()
+	return &Queue{
+		handlers: make(map[string]registration),
+		wake:     make(chan struct{}, 1),
+		workerID: fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+// JobType is a typed handle used to enqueue jobs of one registered type
+type JobType[T any] struct {
+	queue       *Queue
+	name        string
+	maxAttempts int
+}
+
+// Register adds a handler for jobs of type name and returns a handle to
+// enqueue them. Handlers must be idempotent: a job may run again if a worker
+// dies mid-way. Each run has DefaultTimeout to finish.
+func Register[T any](q *Queue, name string, maxAttempts int, handler func(ctx context.Context, payload T) error) *JobType[T] {
+	return RegisterWithTimeout(q, name, maxAttempts, DefaultTimeout, handler)
+}
+
+// RegisterWithTimeout is Register for job types whose runs need a time limit
+// other than DefaultTimeout. The handler's ctx is cancelled once timeout
+// passes, and handlers must return when it is. A job still running a minute
+// after that is assumed to have lost its worker and is claimed again.
+func RegisterWithTimeout[T any](q *Queue, name string, maxAttempts int, timeout time.Duration, handler func(ctx context.Context, payload T) error) *JobType[T] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.handlers[name] = registration{
+		handler: func(ctx context.Context, raw []byte) error {
+			var payload T
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return fmt.Errorf("failed to decode payload: %w", err)
+			}
+			return handler(ctx, payload)
+		},
+		timeout: timeout,
+	}
+	return &JobType[T]{queue: q, name: name, maxAttempts: maxAttempts}
+}
+
+// Name returns the registered job type name
+func (t *JobType[T]) Name() string {
+	return t.name
+}
+
+// Enqueue schedules a job to run as soon as a worker is free
+func (t *JobType[T]) Enqueue(payload T) (*models.Job, error) {
+	return t.EnqueueAt(payload, "", time.Now())
+}
+
+// EnqueueUnique schedules a job unless one with the same key is already
+// pending, in which case the existing job is returned
+func (t *JobType[T]) EnqueueUnique(payload T, key string) (*models.Job, error) {
+	return t.EnqueueAt(payload, key, time.Now())
+}
+
+// EnqueueAt schedules a job to run at runAt. A non-empty key deduplicates
+// against pending jobs of the same type.
+func (t *JobType[T]) EnqueueAt(payload T, key string, runAt time.Time) (*models.Job, error) {
+	if t == nil {
+		return nil, ErrNotRegistered
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        t.name,
+		Key:         key,
+		Payload:     string(raw),
+		Status:      models.JobPending,
+		MaxAttempts: t.maxAttempts,
+		RunAt:       runAt,
+	}
+
+	err = db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if key != "" {
+			var existing models.Job
+			err := tx.Where("type = ? AND key = ? AND status = ?", t.name, key, models.JobPending).First(&existing).Error
+			if err == nil {
+				*job = existing
+				return nil
+			}
+			if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		return tx.Create(job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.queue.notify()
+	return job, nil
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs workers goroutines until ctx is cancelled
+func (q *Queue) Start(ctx context.Context, workers int) {
+	log.Printf("Starting %d job workers", workers)
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		job, err := q.claim()
+		if err != nil {
+			log.Printf("Failed to claim job: %v", err)
+		}
+		if job != nil {
+			q.run(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// claim locks the next runnable job, reclaiming jobs whose worker died
+func (q *Queue) claim() (*models.Job, error) {
+	var job models.Job
+	now := time.Now()
+
+	err := db.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Running jobs are reclaimed once their type's time limit has passed
+		q.mu.RLock()
+		types := make([]string, 0, len(q.handlers))
+		byTimeout := make(map[time.Duration][]string)
+		for name, reg := range q.handlers {
+			types = append(types, name)
+			byTimeout[reg.timeout] = append(byTimeout[reg.timeout], name)
+		}
+		q.mu.RUnlock()
+
+		runnable := "(status = ? AND run_at <= ?)"
+		args := []interface{}{models.JobPending, now}
+		for timeout, names := range byTimeout {
+			runnable += " OR (status = ? AND type IN ? AND locked_at < ?)"
+			args = append(args, models.JobRunning, names, now.Add(-timeout-lockGrace))
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("type IN ?", types).
+			Where(runnable, args...).
+			Order("run_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedAt = &now
+		job.LockedBy = q.workerID
+		return tx.Save(&job).Error
+	})
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	q.mu.RLock()
+	reg := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+
+	err := safeRun(ctx, reg.handler, []byte(job.Payload))
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_at": nil,
+		"locked_by": "",
+	}
+
+	switch {
+	case err == nil:
+		updates["status"] = models.JobSucceeded
+		updates["completed_at"] = now
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed permanently after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		updates["status"] = models.JobDead
+		updates["completed_at"] = now
+		updates["last_error"] = err.Error()
+	default:
+		delay := backoff(job.Attempts)
+		log.Printf("Job %d (%s) failed on attempt %d, retrying in %s: %v", job.ID, job.Type, job.Attempts, delay, err)
+		updates["status"] = models.JobPending
+		updates["run_at"] = now.Add(delay)
+		updates["last_error"] = err.Error()
+	}
+
+	if err := db.GetDB().Model(job).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record result of job %d: %v", job.ID, err)
+	}
+}
+
+func safeRun(ctx context.Context, handler handlerFunc, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// backoff returns an exponential delay with jitter for the given attempt
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff << uint(attempt-1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
+
+// List returns jobs filtered by status and type, newest first
+func (q *Queue) List(status, jobType string, limit, offset int) ([]models.Job, error) {
+	query := db.GetDB().Model(&models.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var jobs []models.Job
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, err
+}
+
+// Get returns a job by ID
+func (q *Queue) Get(id uint) (*models.Job, error) {
+	var job models.Job
+	err := db.GetDB().First(&job, id).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Job")
+	}
+	return &job, err
+}
+
+// Stats counts jobs per type and status
+type Stats struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+func (q *Queue) Stats() ([]Stats, error) {
+	var stats []Stats
+	err := db.GetDB().Model(&models.Job{}).
+		Select("type, status, COUNT(*) AS count").
+		Group("type, status").
+		Order("type, status").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// Retry requeues a dead job with a fresh set of attempts
+func (q *Queue) Retry(id uint) (*models.Job, error) {
+	job, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobDead {
+		return nil, errors.NewValidationError("status", "Only dead jobs can be retried")
+	}
+
+	if err := db.GetDB().Model(job).Updates(map[string]interface{}{
+		"status":       models.JobPending,
+		"attempts":     0,
+		"run_at":       time.Now(),
+		"completed_at": nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	q.notify()
+	return q.Get(id)
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
 
 	"sample-exchange/backend/api"
+	"sample-exchange/backend/audio"
 	"sample-exchange/backend/auth/oauth"
 	"sample-exchange/backend/config"
 	"sample-exchange/backend/db"
+	"sample-exchange/backend/jobs"
 	"sample-exchange/backend/middleware"
 	"sample-exchange/backend/storage"
 
@@ -25,7 +28,13 @@ func main() {
 	}
 
 	// Initialize storage
-	store := storage.NewStorage(cfg)
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to setup storage: %v", err)
+	}
+
+	// Configure audio decoding
+	audio.SetFFmpegPath(cfg.FFmpegPath)
 
 	// Initialize router
 	r := gin.Default()
@@ -76,8 +85,10 @@ func main() {
 		}
 	}
 
-	// Initialize other API routes
-	api.Init(r, store, cfg)
+	// Initialize other API routes and their background jobs
+	queue := jobs.NewQueue()
+	api.Init(r, store, queue, cfg)
+	queue.Start(context.Background(), cfg.JobWorkers)
 
 	// Health check endpoint that matches the one in the K8s config
 	r.GET("/api/v1/health", func(c *gin.Context) {
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -21,30 +22,77 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		// Check if it's a Bearer token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
+		if err := authenticate(c, authHeader); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Validate token
-		claims, err := auth.ValidateToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Next()
+	}
+}
+
+// OptionalAuth sets the user info like Auth when a valid token is present,
+// but lets anonymous requests through
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			if err := authenticate(c, authHeader); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// StreamAuth is Auth for event streams and sockets, which browsers open
+// without custom headers, so the token may also be passed as the
+// access_token query parameter
+func StreamAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if token := c.Query("access_token"); authHeader == "" && token != "" {
+			authHeader = "Bearer " + token
+		}
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "no authorization header"})
 			c.Abort()
 			return
 		}
 
-		// Set user info in context
-		c.Set("user_id", int((*claims)["id"].(float64)))
-		c.Set("email", (*claims)["email"].(string))
+		if err := authenticate(c, authHeader); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
 
+// authenticate validates a bearer token and sets the user info in context
+func authenticate(c *gin.Context, authHeader string) error {
+	// Check if it's a Bearer token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return errors.New("invalid authorization header")
+	}
+
+	// Validate token
+	claims, err := auth.ValidateToken(parts[1])
+	if err != nil {
+		return err
+	}
+
+	// Set user info in context
+	c.Set("user_id", int((*claims)["id"].(float64)))
+	c.Set("email", (*claims)["email"].(string))
+	return nil
+}
+
 // RequireAdmin middleware checks if the user is an admin
 func RequireAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
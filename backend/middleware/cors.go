@@ -7,20 +7,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// AllowedOrigin reports whether the frontend at origin may call the API
+func AllowedOrigin(origin string) bool {
+	allowedOrigin := fmt.Sprintf("http://%s:3000", os.Getenv("HOST_DOMAIN"))
+	return origin == allowedOrigin || origin == "http://localhost:3000"
+}
+
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
-		hostDomain := os.Getenv("HOST_DOMAIN")
-		allowedOrigin := fmt.Sprintf("http://%s:3000", hostDomain)
 
-		if origin == allowedOrigin || origin == "http://localhost:3000" {
+		if AllowedOrigin(origin) {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, HEAD, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, X-Sample-ID, X-Submission-ID")
 
-		if c.Request.Method == "OPTIONS" {
+		// Only answer CORS preflights here; other OPTIONS requests such as
+		// tus discovery reach their routes
+		if c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(204)
 			return
 		}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -10,7 +11,7 @@ import (
 )
 
 const (
-	maxFileSize = 50 * 1024 * 1024 // 50MB
+	MaxFileSize = 50 * 1024 * 1024 // 50MB
 )
 
 var (
@@ -31,8 +32,22 @@ var (
 		".aiff": true,
 		".flac": true,
 	}
+
+	ErrFileTooLarge    = errors.New("File size exceeds maximum limit of 50MB")
+	ErrInvalidFileType = errors.New("Invalid file type. Allowed types: WAV, MP3, AIFF, FLAC")
 )
 
+// CheckUploadFile applies the upload size and file type limits to a file
+func CheckUploadFile(filename string, size int64) error {
+	if size > MaxFileSize {
+		return ErrFileTooLarge
+	}
+	if !allowedFileExtensions[strings.ToLower(filepath.Ext(filename))] {
+		return ErrInvalidFileType
+	}
+	return nil
+}
+
 // SecurityHeaders adds security-related headers to all responses
 func SecurityHeaders() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -76,19 +91,10 @@ func ValidateFileUpload() gin.HandlerFunc {
 			return
 		}
 
-		// Check file size
-		if file.Size > maxFileSize {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": "File size exceeds maximum limit of 50MB",
-			})
-			return
-		}
-
-		// Check file extension
-		ext := strings.ToLower(filepath.Ext(file.Filename))
-		if !allowedFileExtensions[ext] {
+		// Check file size and extension
+		if err := CheckUploadFile(file.Filename, file.Size); err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid file type. Allowed types: WAV, MP3, AIFF, FLAC",
+				"error": err.Error(),
 			})
 			return
 		}
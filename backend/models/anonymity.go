@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Anonymity configures blind listening for a pack. While it is on,
+// submissions are listed without their authors until RevealAt, or the end
+// of the submission window when RevealAt is nil.
+type Anonymity struct {
+	AnonymousSubmissions bool       `json:"anonymousSubmissions" gorm:"default:false"`
+	RevealAt             *time.Time `json:"revealAt"`
+}
+
+// RevealTime returns when authorship of the pack's submissions becomes public
+func (p *SamplePack) RevealTime() time.Time {
+	if p.RevealAt != nil {
+		return *p.RevealAt
+	}
+	return p.EndDate
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Kinds of notification email, also used in unsubscribe links
+const (
+	EmailPackOpened    = "pack_opened"
+	EmailUploadClosing = "upload_closing"
+	EmailWeeklyDigest  = "weekly_digest"
+)
+
+// EmailPreferences holds which notification emails a user receives. Users
+// without a row get every kind.
+type EmailPreferences struct {
+	UserID        uint      `json:"-" gorm:"primarykey;autoIncrement:false"`
+	CreatedAt     time.Time `json:"-"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	PackOpened    bool      `json:"packOpened" gorm:"not null;default:true"`
+	UploadClosing bool      `json:"uploadClosing" gorm:"not null;default:true"`
+	WeeklyDigest  bool      `json:"weeklyDigest" gorm:"not null;default:true"`
+
+	// UnsubscribeToken authenticates unsubscribe links, which are opened
+	// without logging in
+	UnsubscribeToken string     `json:"-" gorm:"uniqueIndex;not null"`
+	LastDigestAt     *time.Time `json:"-"`
+}
+
+// SentEmail records a pack email sent to a user, so that retried jobs
+// never send it twice
+type SentEmail struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserID    uint   `gorm:"uniqueIndex:idx_sent_emails_user_kind_pack;not null"`
+	Kind      string `gorm:"uniqueIndex:idx_sent_emails_user_kind_pack;not null"`
+	PackID    uint   `gorm:"uniqueIndex:idx_sent_emails_user_kind_pack;not null"`
+}
+
+// Wants reports whether the preferences allow an email kind
+func (p *EmailPreferences) Wants(kind string) bool {
+	switch kind {
+	case EmailPackOpened:
+		return p.PackOpened
+	case EmailUploadClosing:
+		return p.UploadClosing
+	case EmailWeeklyDigest:
+		return p.WeeklyDigest
+	}
+	return false
+}
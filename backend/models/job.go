@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead" // failed on every attempt
+)
+
+// Job is a unit of background work persisted in the job queue
+type Job struct {
+	ID          uint       `json:"ID" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Type        string     `json:"type" gorm:"not null;index"`
+	Key         string     `json:"key" gorm:"index"` // deduplicates pending jobs of the same type
+	Payload     string     `json:"payload" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"not null;index"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	RunAt       time.Time  `json:"runAt" gorm:"index"`
+	LockedAt    *time.Time `json:"lockedAt"`
+	LockedBy    string     `json:"lockedBy"`
+	LastError   string     `json:"lastError"`
+	CompletedAt *time.Time `json:"completedAt"`
+}
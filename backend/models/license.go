@@ -0,0 +1,89 @@
+package models
+
+// License identifies the terms under which a sample may be reused
+type License string
+
+const (
+	LicenseCC0      License = "CC0"
+	LicenseCCBY     License = "CC-BY"
+	LicenseCCBYNC   License = "CC-BY-NC"
+	LicensePackOnly License = "pack-only" // only for submissions to the pack it was uploaded to
+
+	DefaultLicense = LicensePackOnly
+)
+
+// LicenseTerms describes what a license allows
+type LicenseTerms struct {
+	License             License `json:"license"`
+	Name                string  `json:"name"`
+	URL                 string  `json:"url,omitempty"`
+	RequiresAttribution bool    `json:"requiresAttribution"`
+	CommercialUse       bool    `json:"commercialUse"`
+
+	// PackOnly restricts use of the sample to submissions to its pack. It
+	// says nothing about how those submissions may be used.
+	PackOnly bool `json:"packOnly"`
+}
+
+var licenseTerms = map[License]LicenseTerms{
+	LicenseCC0: {
+		License:       LicenseCC0,
+		Name:          "CC0 1.0 Universal",
+		URL:           "https://creativecommons.org/publicdomain/zero/1.0/",
+		CommercialUse: true,
+	},
+	LicenseCCBY: {
+		License:             LicenseCCBY,
+		Name:                "Creative Commons Attribution 4.0",
+		URL:                 "https://creativecommons.org/licenses/by/4.0/",
+		RequiresAttribution: true,
+		CommercialUse:       true,
+	},
+	LicenseCCBYNC: {
+		License:             LicenseCCBYNC,
+		Name:                "Creative Commons Attribution-NonCommercial 4.0",
+		URL:                 "https://creativecommons.org/licenses/by-nc/4.0/",
+		RequiresAttribution: true,
+	},
+	LicensePackOnly: {
+		License:             LicensePackOnly,
+		Name:                "Pack only: may be used solely in submissions to this pack",
+		RequiresAttribution: true,
+		CommercialUse:       true,
+		PackOnly:            true,
+	},
+}
+
+// Valid reports whether l is one of the supported licenses
+func (l License) Valid() bool {
+	_, ok := licenseTerms[l]
+	return ok
+}
+
+// Terms returns the terms of l, treating unknown or empty licenses as the default
+func (l License) Terms() LicenseTerms {
+	if terms, ok := licenseTerms[l]; ok {
+		return terms
+	}
+	return licenseTerms[DefaultLicense]
+}
+
+// NonCommercialLicenses returns the licenses that forbid commercial use of
+// derived works
+func NonCommercialLicenses() []License {
+	var licenses []License
+	for l, terms := range licenseTerms {
+		if !terms.CommercialUse {
+			licenses = append(licenses, l)
+		}
+	}
+	return licenses
+}
+
+// SampleCredit is the attribution and license information for a sample
+type SampleCredit struct {
+	SampleID    uint         `json:"sampleId"`
+	Filename    string       `json:"filename"`
+	Contributor string       `json:"contributor"`
+	Terms       LicenseTerms `json:"terms"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Links is a list of URLs stored as a newline-separated text column, since
+// URLs may contain commas
+type Links []string
+
+func (l Links) Value() (driver.Value, error) {
+	return strings.Join(l, "\n"), nil
+}
+
+func (l *Links) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Links", value)
+	}
+
+	if s == "" {
+		*l = Links{}
+		return nil
+	}
+	*l = strings.Split(s, "\n")
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ListeningRoom is a listening party where a host plays a pack's
+// submissions to everyone in the room at the same time
+type ListeningRoom struct {
+	ID           uint       `json:"ID" gorm:"primarykey"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	Title        string     `json:"title"`
+	HostID       uint       `json:"hostID" gorm:"not null;index"`
+	Host         *User      `json:"host,omitempty" gorm:"foreignKey:HostID"`
+	SamplePackID uint       `json:"samplePackID" gorm:"not null;index"`
+	SamplePack   SamplePack `json:"samplePack" gorm:"foreignKey:SamplePackID"`
+	ClosedAt     *time.Time `json:"closedAt"`
+
+	// Participants is how many people are connected right now
+	Participants int `json:"participants" gorm:"-"`
+}
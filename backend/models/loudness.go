@@ -0,0 +1,52 @@
+package models
+
+import "sample-exchange/backend/audio"
+
+// Loudness statuses. Uploads are measured in the background after they are
+// stored, and removed if they exceed their pack's limits.
+const (
+	LoudnessPending  = "pending"
+	LoudnessDone     = "done"
+	LoudnessFailed   = "failed"
+	LoudnessRejected = "rejected"
+)
+
+// Loudness holds the loudness measurements of an uploaded file. Fields are
+// nil until it is measured, or when it could not be decoded.
+type Loudness struct {
+	LoudnessStatus string   `json:"loudnessStatus"`
+	LoudnessLUFS   *float64 `json:"loudnessLufs"`
+	TruePeakDBTP   *float64 `json:"truePeakDbtp"`
+	ClippedSamples *int     `json:"clippedSamples"`
+}
+
+// Warnings returns warnings for measurements above the advisory levels
+func (l Loudness) Warnings() []string {
+	if l.LoudnessLUFS == nil || l.TruePeakDBTP == nil || l.ClippedSamples == nil {
+		return nil
+	}
+	measured := audio.Loudness{
+		IntegratedLUFS: *l.LoudnessLUFS,
+		TruePeakDBTP:   *l.TruePeakDBTP,
+		ClippedSamples: *l.ClippedSamples,
+	}
+	return measured.Warnings()
+}
+
+// NewLoudness converts measurements to their stored form
+func NewLoudness(l *audio.Loudness) Loudness {
+	return Loudness{
+		LoudnessStatus: LoudnessDone,
+		LoudnessLUFS:   &l.IntegratedLUFS,
+		TruePeakDBTP:   &l.TruePeakDBTP,
+		ClippedSamples: &l.ClippedSamples,
+	}
+}
+
+// LoudnessLimits are the optional per-pack limits enforced on uploads and
+// submissions. A nil field is not enforced.
+type LoudnessLimits struct {
+	MaxLoudnessLUFS   *float64 `json:"maxLoudnessLufs"`
+	MaxTruePeakDBTP   *float64 `json:"maxTruePeakDbtp"`
+	MaxClippedSamples *int     `json:"maxClippedSamples"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationPackTracks     = "pack_tracks"     // tracks were made from a closed pack the user contributed samples to
+	NotificationUploadRejected = "upload_rejected" // an upload exceeded its pack's loudness limits
+)
+
+// Notification is an in-app message to one user
+type Notification struct {
+	ID        uint       `json:"ID" gorm:"primarykey"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UserID    uint       `json:"-" gorm:"not null;uniqueIndex:idx_notifications_user_key"`
+	Type      string     `json:"type" gorm:"not null"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Link      string     `json:"link,omitempty"` // frontend path the notification is about
+	ReadAt    *time.Time `json:"readAt"`
+
+	// Key deduplicates notifications about the same thing, nil when they
+	// may repeat
+	Key *string `json:"-" gorm:"uniqueIndex:idx_notifications_user_key"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// PackVariant is a cached pack archive with every sample converted to a
+// uniform export format
+type PackVariant struct {
+	ID           uint      `json:"ID" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	SamplePackID uint      `json:"samplePackID" gorm:"uniqueIndex:idx_pack_variants_pack_format"`
+	Format       string    `json:"format" gorm:"uniqueIndex:idx_pack_variants_pack_format"` // e.g. "wav-48k-24"
+	ArchivePath  string    `json:"-"`
+	ArchiveHash  string    `json:"archiveHash"`
+	ArchiveSize  int64     `json:"archiveSize"`
+}
@@ -0,0 +1,14 @@
+package models
+
+const (
+	PreviewPending = "pending"
+	PreviewReady   = "ready"
+	PreviewFailed  = "failed"
+)
+
+// Preview tracks the low-bitrate listening copy of an uploaded file
+type Preview struct {
+	PreviewPath   string `json:"-"`
+	PreviewStatus string `json:"previewStatus"` // pending, ready or failed
+	PreviewURL    string `json:"previewUrl" gorm:"-"`
+}
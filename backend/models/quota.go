@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Quota limits how many files and bytes a user may upload. A nil field falls
+// back to the next level of defaults and a negative one means unlimited.
+type Quota struct {
+	MaxFiles *int   `json:"maxFiles"`
+	MaxBytes *int64 `json:"maxBytes"`
+}
+
+// UserQuota is an admin override of a user's quotas
+type UserQuota struct {
+	UserID    uint      `json:"userID" gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Total     Quota     `json:"total" gorm:"embedded;embeddedPrefix:total_"`      // across all packs
+	PerPack   Quota     `json:"perPack" gorm:"embedded;embeddedPrefix:per_pack_"` // samples in any one pack
+}
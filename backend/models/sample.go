@@ -15,8 +15,39 @@ type Sample struct {
 	FileURL      string         `json:"fileUrl" gorm:"-"`
 	FilePath     string         `json:"-"`
 	FileSize     int64          `json:"fileSize"`
+	SHA256       string         `json:"sha256"` // hex digest of the stored file
+	Format       string         `json:"format"`
+	SampleRate   int            `json:"sampleRate"`
+	BitDepth     int            `json:"bitDepth"`
+	Channels     int            `json:"channels"`
+	Duration     float64        `json:"duration"` // seconds
+	License      License        `json:"license" gorm:"default:'pack-only'"`
+	Description  string         `json:"description"`
+	Category     string         `json:"category" gorm:"index"`
+	Tags         Tags           `json:"tags" gorm:"type:text"`
+	BPM          float64        `json:"bpm"`
+	Key          string         `json:"key"`       // canonical form, e.g. "A minor"
+	BPMSource    string         `json:"bpmSource"` // "user" or "analysis"
+	KeySource    string         `json:"keySource"` // "user" or "analysis"
 	UserID       uint           `json:"userID"`
 	User         User           `json:"user" gorm:"foreignKey:UserID"`
 	SamplePackID uint           `json:"samplePackID"`
 	SamplePack   SamplePack     `json:"samplePack" gorm:"foreignKey:SamplePackID"`
+
+	// Background analysis results, kept even when the uploader overrides them
+	AnalysisStatus string  `json:"analysisStatus"` // pending, done, failed or skipped
+	EstimatedBPM   float64 `json:"estimatedBpm"`
+	BPMConfidence  float64 `json:"bpmConfidence"`
+	EstimatedKey   string  `json:"estimatedKey"`
+	KeyConfidence  float64 `json:"keyConfidence"`
+
+	Loudness `gorm:"embedded"`
+	Preview  `gorm:"embedded"`
+	Warnings []string `json:"warnings,omitempty" gorm:"-"`
+}
+
+// AfterFind fills in the loudness warnings
+func (s *Sample) AfterFind(tx *gorm.DB) error {
+	s.Warnings = s.Loudness.Warnings()
+	return nil
 }
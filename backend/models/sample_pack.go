@@ -58,6 +58,29 @@ type SamplePack struct {
 	StartDate   time.Time      `json:"startDate"`      // Monday 00:00
 	EndDate     time.Time      `json:"endDate"`        // Friday 23:59:59 (12 days later)
 	IsActive    bool           `json:"isActive" gorm:"default:false"`
+
+	// When window changes were announced, nil until they happen
+	UploadsClosingAt *time.Time `json:"uploadsClosingAt"`
+	UploadsClosedAt  *time.Time `json:"uploadsClosedAt"`
+	OpenedAt         *time.Time `json:"openedAt"`
+	ClosedAt         *time.Time `json:"closedAt"`
+
+	ArtworkURL  string         `json:"artworkUrl"`
+	ArchivePath string         `json:"-"`
+	ArchiveHash string         `json:"archiveHash"` // SHA-256 of the cached pack zip
+	ArchiveSize int64          `json:"archiveSize"`
 	Samples     []Sample       `json:"samples"`
 	Submissions []Submission   `json:"submissions"`
+
+	// Totals for the whole pack, set even when Samples is limited to the
+	// viewer's own uploads before the pack opens
+	SampleCount      int  `json:"sampleCount" gorm:"-"`
+	ContributorCount int  `json:"contributorCount" gorm:"-"`
+	IsOpen           bool `json:"isOpen" gorm:"-"`
+
+	LoudnessLimits `gorm:"embedded"`
+	Anonymity      `gorm:"embedded"`
+
+	// UploadQuota limits the samples each user may add to this pack
+	UploadQuota Quota `json:"uploadQuota" gorm:"embedded;embeddedPrefix:upload_quota_"`
 }
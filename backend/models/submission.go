@@ -24,9 +24,32 @@ type Submission struct {
 	FileURL      string         `json:"fileUrl" gorm:"-"`
 	FilePath     string         `json:"-"`
 	FileSize     int64          `json:"fileSize"`
+	SHA256       string         `json:"sha256"`   // hex digest of the stored file
+	Duration     float64        `json:"duration"` // seconds, 0 if the file could not be probed
 	UserID       uint           `json:"userID"`
-	User         User           `json:"user" gorm:"foreignKey:UserID"`
+	User         *User          `json:"user" gorm:"foreignKey:UserID"`
 	SamplePackID uint           `json:"samplePackID"`
 	SamplePack   SamplePack     `json:"samplePack" gorm:"foreignKey:SamplePackID"`
 	SubmittedAt  time.Time      `json:"submittedAt"`
+
+	// Anonymous is set when the author has been hidden from the viewer
+	// because the pack is in blind listening mode
+	Anonymous bool `json:"anonymous" gorm:"-"`
+
+	// NonCommercial is set when the pack contains samples whose license
+	// forbids commercial use of derived works. Which samples a track used is
+	// not recorded, so this is a pack-level flag, kept in step as the pack's
+	// samples change.
+	NonCommercial bool           `json:"nonCommercial" gorm:"default:false"`
+	SampleCredits []SampleCredit `json:"sampleCredits,omitempty" gorm:"-"`
+
+	Loudness `gorm:"embedded"`
+	Preview  `gorm:"embedded"`
+	Warnings []string `json:"warnings,omitempty" gorm:"-"`
+}
+
+// AfterFind fills in the loudness warnings
+func (s *Submission) AfterFind(tx *gorm.DB) error {
+	s.Warnings = s.Loudness.Warnings()
+	return nil
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Tags is a list of normalized tags stored as a comma-separated text column
+type Tags []string
+
+func (t Tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func (t *Tags) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", value)
+	}
+
+	if s == "" {
+		*t = Tags{}
+		return nil
+	}
+	*t = strings.Split(s, ",")
+	return nil
+}
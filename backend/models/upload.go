@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	UploadKindSample     = "sample"
+	UploadKindSubmission = "submission"
+)
+
+// Upload tracks a resumable upload in progress. Once every byte has arrived
+// the file becomes a Sample or Submission.
+type Upload struct {
+	ID           string     `json:"id" gorm:"primarykey;size:32"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	UserID       uint       `json:"userID" gorm:"index;not null"`
+	Kind         string     `json:"kind" gorm:"not null"`
+	SamplePackID uint       `json:"samplePackID"`
+	Filename     string     `json:"filename"`
+	Length       int64      `json:"length"`
+	Offset       int64      `json:"offset"`
+	Metadata     string     `json:"-" gorm:"type:text"` // JSON encoded Upload-Metadata
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"index"`
+	CompletedAt  *time.Time `json:"completedAt"`
+	SampleID     *uint      `json:"sampleID,omitempty"`
+	SubmissionID *uint      `json:"submissionID,omitempty"`
+}
@@ -12,9 +12,21 @@ type User struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	Email    string `json:"email" gorm:"unique;not null"`
+	Email    string `json:"-" gorm:"unique;not null"` // private; only /auth/current-user returns it
 	Name     string `json:"name"`
 	Provider string `json:"provider"` // OAuth provider (github, google, discord)
 	Avatar   string `json:"avatar"`   // URL to user's avatar
 	IsAdmin  bool   `json:"isAdmin" gorm:"default:false"` // Whether the user has admin privileges
+
+	// FeedToken authenticates podcast feed requests, which cannot send a bearer token
+	FeedToken string `json:"-" gorm:"index"`
+
+	// Public profile, shown alongside Name and Avatar
+	Bio   string `json:"bio"`
+	Links Links  `json:"links" gorm:"type:text"`
+
+	// CustomName and CustomAvatar are set once the user edits their name
+	// or avatar, so logging in no longer replaces it with the provider's
+	CustomName   bool `json:"-" gorm:"default:false"`
+	CustomAvatar bool `json:"-" gorm:"default:false"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // gave up after every attempt
+)
+
+// Webhook is an admin-managed subscription that receives events as signed
+// HTTP POST requests
+type Webhook struct {
+	ID          uint           `json:"ID" gorm:"primarykey"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	URL         string         `json:"url" gorm:"not null"`
+	Secret      string         `json:"-" gorm:"not null"`       // HMAC key, only shown when created
+	Events      Tags           `json:"events" gorm:"type:text"` // empty for every event
+	Description string         `json:"description"`
+	Active      bool           `json:"active" gorm:"default:true"`
+}
+
+// WebhookDelivery logs one event sent to a webhook and the outcome of its
+// latest attempt
+type WebhookDelivery struct {
+	ID             uint       `json:"ID" gorm:"primarykey"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	WebhookID      uint       `json:"webhookID" gorm:"index;not null"`
+	Event          string     `json:"event" gorm:"not null"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"not null;index"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus"`
+	ResponseBody   string     `json:"responseBody" gorm:"type:text"` // truncated
+	LastError      string     `json:"lastError"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	RedeliveryOf   *uint      `json:"redeliveryOf,omitempty"`
+}
@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client calls the parts of the Discord REST API the bot uses
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		token:   token,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Message is a message posted to a channel
+type Message struct {
+	Content         string           `json:"content"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+	Flags           int              `json:"flags,omitempty"`
+}
+
+// AllowedMentions limits who a message pings. An empty Parse list keeps
+// user supplied titles from mentioning @everyone.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// Command is an application (slash) command definition
+type Command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+// CommandOption is an option or subcommand, both in command definitions and
+// in invoked interactions
+type CommandOption struct {
+	Type        int             `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Required    bool            `json:"required,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+// Command option types
+const (
+	OptionSubcommand = 1
+	OptionInteger    = 4
+)
+
+// CreateMessage posts a message to a channel
+func (c *Client) CreateMessage(ctx context.Context, channelID string, msg Message) error {
+	return c.do(ctx, http.MethodPost, "/channels/"+channelID+"/messages", msg)
+}
+
+// OverwriteCommands replaces the application's commands with commands, in
+// one server when guildID is set or globally otherwise
+func (c *Client) OverwriteCommands(ctx context.Context, applicationID, guildID string, commands []Command) error {
+	path := "/applications/" + applicationID + "/commands"
+	if guildID != "" {
+		path = "/applications/" + applicationID + "/guilds/" + guildID + "/commands"
+	}
+	return c.do(ctx, http.MethodPut, path, commands)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DiscordBot (https://quixit.us, 1)")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("discord %s %s failed with status %d: %s", method, path, resp.StatusCode, excerpt)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+)
+
+// Interaction and response types
+const (
+	InteractionPing    = 1
+	InteractionCommand = 2
+
+	ResponsePong    = 1
+	ResponseMessage = 4 // reply in the channel the command was used in
+)
+
+// maxListed is how many submissions /submissions lists
+const maxListed = 10
+
+// Interaction is a request Discord sends to the interactions endpoint
+type Interaction struct {
+	ID        string       `json:"id"`
+	Type      int          `json:"type"`
+	Token     string       `json:"token"`
+	GuildID   string       `json:"guild_id,omitempty"`
+	ChannelID string       `json:"channel_id,omitempty"`
+	Data      *CommandData `json:"data,omitempty"`
+}
+
+// CommandData is the invoked command of an interaction
+type CommandData struct {
+	Name    string          `json:"name"`
+	Options []CommandOption `json:"options,omitempty"`
+}
+
+// InteractionResponse answers an interaction
+type InteractionResponse struct {
+	Type int      `json:"type"`
+	Data *Message `json:"data,omitempty"`
+}
+
+// commands are the slash commands the bot registers
+var commands = []Command{
+	{
+		Name:        "pack",
+		Description: "Sample pack info",
+		Options: []CommandOption{
+			{Type: OptionSubcommand, Name: "current", Description: "Show the current pack"},
+			{Type: OptionSubcommand, Name: "deadline", Description: "Show the next deadline of the current pack"},
+		},
+	},
+	{
+		Name:        "submissions",
+		Description: "List the latest submissions",
+		Options: []CommandOption{
+			{Type: OptionInteger, Name: "pack", Description: "Pack ID, the current pack when left out"},
+		},
+	},
+}
+
+// VerifyInteraction checks the Ed25519 signature Discord sends with every
+// interaction
+func (s *Service) VerifyInteraction(signature, timestamp string, body []byte) error {
+	key, err := hex.DecodeString(s.cfg.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid DISCORD_PUBLIC_KEY")
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, append([]byte(timestamp), body...), sig) {
+		return errors.NewAuthenticationError("Invalid request signature")
+	}
+	return nil
+}
+
+// HandleInteraction answers pings and slash commands
+func (s *Service) HandleInteraction(interaction *Interaction) (*InteractionResponse, error) {
+	switch interaction.Type {
+	case InteractionPing:
+		return &InteractionResponse{Type: ResponsePong}, nil
+	case InteractionCommand:
+		if interaction.Data == nil {
+			return nil, errors.NewValidationError("data", "Command data is required")
+		}
+	default:
+		return nil, errors.NewValidationError("type", "Unsupported interaction type")
+	}
+
+	var content string
+	var err error
+	switch data := interaction.Data; data.Name {
+	case "pack":
+		if len(data.Options) == 0 {
+			return nil, errors.NewValidationError("options", "Subcommand is required")
+		}
+		switch data.Options[0].Name {
+		case "current":
+			content, err = s.currentPack()
+		case "deadline":
+			content, err = s.deadline()
+		default:
+			return nil, errors.NewValidationError("options", "Unknown subcommand")
+		}
+	case "submissions":
+		var packID uint
+		for _, opt := range data.Options {
+			if opt.Name == "pack" {
+				if err := json.Unmarshal(opt.Value, &packID); err != nil {
+					return nil, errors.NewValidationError("pack", "Pack must be an ID")
+				}
+			}
+		}
+		content, err = s.latestSubmissions(packID)
+	default:
+		return nil, errors.NewValidationError("name", "Unknown command")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &InteractionResponse{Type: ResponseMessage, Data: &Message{
+		Content:         content,
+		AllowedMentions: &AllowedMentions{Parse: []string{}},
+	}}, nil
+}
+
+func (s *Service) currentPack() (string, error) {
+	pack, err := s.packService.GetCurrentPack()
+	if err != nil || pack == nil {
+		return "There is no pack running right now.", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n", pack.Title)
+	if pack.Description != "" {
+		fmt.Fprintf(&b, "%s\n", pack.Description)
+	}
+	b.WriteString(phase(pack, time.Now()))
+	fmt.Fprintf(&b, "\n%s", s.packURL(pack.ID))
+	return b.String(), nil
+}
+
+func (s *Service) deadline() (string, error) {
+	pack, err := s.packService.GetCurrentPack()
+	if err != nil || pack == nil {
+		return "There is no pack running right now.", err
+	}
+
+	now := time.Now()
+	switch {
+	case now.Before(pack.UploadEnd):
+		return fmt.Sprintf("Sample uploads for **%s** close %s.", pack.Title, timestamp(pack.UploadEnd)), nil
+	case now.Before(pack.EndDate):
+		return fmt.Sprintf("Submissions for **%s** close %s.", pack.Title, timestamp(pack.EndDate)), nil
+	default:
+		return fmt.Sprintf("**%s** closed %s.", pack.Title, timestamp(pack.EndDate)), nil
+	}
+}
+
+func (s *Service) latestSubmissions(packID uint) (string, error) {
+	var pack *models.SamplePack
+	var err error
+	if packID == 0 {
+		pack, err = s.packService.GetCurrentPack()
+	} else {
+		pack, err = s.packService.GetPackFor(packID, samplepack.Viewer{})
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("There is no pack %d.", packID), nil
+		}
+	}
+	if err != nil || pack == nil {
+		return "There is no pack running right now.", err
+	}
+
+	// Listed as a guest so blind listening hides authors
+	submissions, err := s.submissionService.ListSubmissionsFor(pack.ID, maxListed, 0, samplepack.Viewer{})
+	if err != nil {
+		return "", err
+	}
+	if len(submissions) == 0 {
+		return fmt.Sprintf("No submissions for **%s** yet.", pack.Title), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Latest submissions for **%s**:\n", pack.Title)
+	for _, sub := range submissions {
+		fmt.Fprintf(&b, "- %s by %s\n", sub.Title, authorName(sub.User))
+	}
+	fmt.Fprintf(&b, "%s", s.packURL(pack.ID))
+	return b.String(), nil
+}
+
+// phase describes which window of a pack is open at now
+func phase(pack *models.SamplePack, now time.Time) string {
+	switch {
+	case now.Before(pack.UploadStart):
+		return "Sample uploads open " + timestamp(pack.UploadStart) + "."
+	case now.Before(pack.UploadEnd):
+		return "Sample uploads are open until " + timestamp(pack.UploadEnd) + "."
+	case now.Before(pack.StartDate):
+		return "Uploads are closed, the pack opens " + timestamp(pack.StartDate) + "."
+	case now.Before(pack.EndDate):
+		return "Submissions are open until " + timestamp(pack.EndDate) + "."
+	default:
+		return "Submissions closed " + timestamp(pack.EndDate) + "."
+	}
+}
+
+// timestamp formats t as a Discord timestamp, which clients show in the
+// reader's time zone along with a relative time
+func timestamp(t time.Time) string {
+	return fmt.Sprintf("<t:%d:F> (<t:%d:R>)", t.Unix(), t.Unix())
+}
+
+func authorName(user *models.User) string {
+	if user == nil {
+		return "an anonymous producer"
+	}
+	return user.Name
+}
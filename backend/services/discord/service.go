@@ -0,0 +1,125 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/services/submission"
+)
+
+const JobAnnounce = "discord.announce"
+
+// AnnounceJob is the payload of announcement jobs
+type AnnounceJob struct {
+	ChannelID string `json:"channelId"`
+	Content   string `json:"content"`
+}
+
+// Service posts announcements to Discord and answers slash commands. It
+// does nothing unless a bot token is configured.
+type Service struct {
+	cfg               config.DiscordBotConfig
+	publicURL         string
+	client            *Client
+	packService       *samplepack.Service
+	submissionService *submission.Service
+	announceJob       *jobs.JobType[AnnounceJob]
+}
+
+func NewService(cfg *config.Config, packService *samplepack.Service, submissionService *submission.Service) *Service {
+	return &Service{
+		cfg:               cfg.DiscordBot,
+		publicURL:         cfg.PublicURL,
+		client:            NewClient(cfg.DiscordBot.APIURL, cfg.DiscordBot.Token),
+		packService:       packService,
+		submissionService: submissionService,
+	}
+}
+
+// Enabled reports whether a bot token is configured
+func (s *Service) Enabled() bool {
+	return s.cfg.Token != ""
+}
+
+// RegisterJobs registers the announcement job with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.announceJob = jobs.Register(q, JobAnnounce, 5, func(ctx context.Context, p AnnounceJob) error {
+		return s.client.CreateMessage(ctx, p.ChannelID, Message{
+			Content:         p.Content,
+			AllowedMentions: &AllowedMentions{Parse: []string{}},
+		})
+	})
+}
+
+// RegisterCommands installs the bot's slash commands
+func (s *Service) RegisterCommands(ctx context.Context) error {
+	if s.cfg.ApplicationID == "" {
+		return fmt.Errorf("DISCORD_APPLICATION_ID is not set")
+	}
+	return s.client.OverwriteCommands(ctx, s.cfg.ApplicationID, s.cfg.GuildID, commands)
+}
+
+// Subscribe queues an announcement for window changes and new submissions
+// published on bus
+func (s *Service) Subscribe(bus *events.Bus) {
+	if !s.Enabled() || s.cfg.ChannelID == "" {
+		return
+	}
+
+	bus.Subscribe(func(event events.Event) {
+		content := s.announcement(event)
+		if content == "" {
+			return
+		}
+		if _, err := s.announceJob.Enqueue(AnnounceJob{ChannelID: s.cfg.ChannelID, Content: content}); err != nil {
+			log.Printf("Failed to queue Discord announcement for %s: %v", event.Type, err)
+		}
+	})
+}
+
+// announcement returns the message for an event, or "" for events that are
+// not announced
+func (s *Service) announcement(event events.Event) string {
+	switch data := event.Data.(type) {
+	case events.PackData:
+		switch event.Type {
+		case events.PackCreated:
+			return fmt.Sprintf("New sample pack **%s**! Upload your samples until %s.\n%s",
+				data.Title, timestamp(data.UploadEnd), s.packURL(data.ID))
+		case events.PackUploadsClosing:
+			return fmt.Sprintf("Sample uploads for **%s** close %s. Get your samples in!\n%s",
+				data.Title, timestamp(data.UploadEnd), s.packURL(data.ID))
+		case events.PackUploadsClosed:
+			return fmt.Sprintf("Sample uploads for **%s** are closed.", data.Title)
+		case events.PackOpened:
+			return fmt.Sprintf("**%s** is open! Download the samples and submit your track until %s.\n%s",
+				data.Title, timestamp(data.EndDate), s.packURL(data.ID))
+		case events.PackClosed:
+			return fmt.Sprintf("Submissions for **%s** are closed. Thanks to everyone who took part!\n%s",
+				data.Title, s.packURL(data.ID))
+		}
+	case events.SubmissionData:
+		var pack models.SamplePack
+		if err := db.GetDB().First(&pack, data.PackID).Error; err != nil {
+			log.Printf("Failed to load pack %d for Discord announcement: %v", data.PackID, err)
+			return ""
+		}
+		author := "an anonymous producer"
+		if data.AuthorName != "" {
+			author = data.AuthorName
+		}
+		return fmt.Sprintf("New submission for **%s**: %s by %s", pack.Title, data.Title, author)
+	}
+	return ""
+}
+
+func (s *Service) packURL(id uint) string {
+	return fmt.Sprintf("%s/packs/%d", s.publicURL, id)
+}
@@ -0,0 +1,144 @@
+package email
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+)
+
+// emailData is what the email templates render
+type emailData struct {
+	Name              string
+	UnsubscribeURL    string
+	UnsubscribeAllURL string
+
+	// Pack emails
+	Pack    *models.SamplePack
+	PackURL string
+
+	// Weekly digest
+	Current        *packSummary
+	NewSubmissions int64
+	Closed         []packSummary
+}
+
+type packSummary struct {
+	Pack        models.SamplePack
+	Phase       string
+	Samples     int64
+	Submissions int64
+	URL         string
+}
+
+// digest fills in the weekly digest for the week before now
+func (s *Service) digest(data *emailData, now time.Time) error {
+	since := now.AddDate(0, 0, -7)
+
+	var current models.SamplePack
+	result := db.GetDB().Where("is_active = ?", true).Limit(1).Find(&current)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		summary, err := s.summarize(current, now)
+		if err != nil {
+			return err
+		}
+		data.Current = &summary
+	}
+
+	if err := db.GetDB().Model(&models.Submission{}).Where("created_at >= ?", since).Count(&data.NewSubmissions).Error; err != nil {
+		return err
+	}
+
+	var closed []models.SamplePack
+	if err := db.GetDB().Where("end_date >= ? AND end_date < ?", since, now).Order("end_date").Find(&closed).Error; err != nil {
+		return err
+	}
+	for _, pack := range closed {
+		summary, err := s.summarize(pack, now)
+		if err != nil {
+			return err
+		}
+		data.Closed = append(data.Closed, summary)
+	}
+	return nil
+}
+
+func (s *Service) summarize(pack models.SamplePack, now time.Time) (packSummary, error) {
+	summary := packSummary{Pack: pack, Phase: phase(&pack, now), URL: s.packURL(pack.ID)}
+	if err := db.GetDB().Model(&models.Sample{}).Where("sample_pack_id = ?", pack.ID).Count(&summary.Samples).Error; err != nil {
+		return summary, err
+	}
+	err := db.GetDB().Model(&models.Submission{}).Where("sample_pack_id = ?", pack.ID).Count(&summary.Submissions).Error
+	return summary, err
+}
+
+// phase describes which window of a pack is open at now
+func phase(pack *models.SamplePack, now time.Time) string {
+	const layout = "Mon, 02 Jan 15:04 MST"
+	switch {
+	case now.Before(pack.UploadStart):
+		return "Sample uploads open " + pack.UploadStart.Format(layout) + "."
+	case now.Before(pack.UploadEnd):
+		return "Sample uploads are open until " + pack.UploadEnd.Format(layout) + "."
+	case now.Before(pack.StartDate):
+		return "Uploads are closed, the pack opens " + pack.StartDate.Format(layout) + "."
+	case now.Before(pack.EndDate):
+		return "Submissions are open until " + pack.EndDate.Format(layout) + "."
+	default:
+		return "Submissions are closed."
+	}
+}
+
+// digestSlot returns the most recent weekly digest time at or before now
+func digestSlot(now time.Time) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), digestHour, 0, 0, 0, now.Location())
+	slot = slot.AddDate(0, 0, -int((now.Weekday()-digestWeekday+7)%7))
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -7)
+	}
+	return slot
+}
+
+// QueueDigests queues the weekly digest for every subscribed user who has
+// not received this week's
+func (s *Service) QueueDigests(now time.Time) error {
+	slot := digestSlot(now)
+
+	var ids []uint
+	err := subscribers(models.EmailWeeklyDigest).
+		Where("email_preferences.last_digest_at IS NULL OR email_preferences.last_digest_at < ?", slot).
+		Pluck("users.id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range ids {
+		key := fmt.Sprintf("%s:%d", models.EmailWeeklyDigest, userID)
+		if _, err := s.sendJob.EnqueueUnique(SendJob{UserID: userID, Kind: models.EmailWeeklyDigest}, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDigestScheduler periodically queues weekly digests that are due
+func (s *Service) RunDigestScheduler(interval time.Duration) {
+	if !s.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.QueueDigests(time.Now()); err != nil {
+			log.Printf("Failed to queue weekly digests: %v", err)
+		}
+		<-ticker.C
+	}
+}
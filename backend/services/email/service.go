@@ -0,0 +1,341 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	JobNotify = "email.notify" // fans a pack email out to every subscriber
+	JobSend   = "email.send"
+
+	// UnsubscribeAll is the unsubscribe kind that turns off every email
+	UnsubscribeAll = "all"
+
+	// The weekly digest goes out after this time each week, server local time
+	digestWeekday = time.Monday
+	digestHour    = 9
+)
+
+// kindColumns maps email kinds to their preference columns
+var kindColumns = map[string]string{
+	models.EmailPackOpened:    "pack_opened",
+	models.EmailUploadClosing: "upload_closing",
+	models.EmailWeeklyDigest:  "weekly_digest",
+}
+
+// NotifyJob is the payload of jobs that queue a pack email for every user
+// who wants it
+type NotifyJob struct {
+	Kind   string `json:"kind"`
+	PackID uint   `json:"packId"`
+}
+
+// SendJob is the payload of jobs that email one user
+type SendJob struct {
+	UserID uint   `json:"userId"`
+	Kind   string `json:"kind"`
+	PackID uint   `json:"packId,omitempty"`
+}
+
+// Service sends notification emails. It does nothing unless an SMTP host is
+// configured, but preferences can be edited either way.
+type Service struct {
+	cfg       config.SMTPConfig
+	publicURL string
+	notifyJob *jobs.JobType[NotifyJob]
+	sendJob   *jobs.JobType[SendJob]
+}
+
+func NewService(cfg *config.Config) *Service {
+	return &Service{
+		cfg:       cfg.SMTP,
+		publicURL: cfg.PublicURL,
+	}
+}
+
+// Enabled reports whether an SMTP host is configured
+func (s *Service) Enabled() bool {
+	return s.cfg.Host != ""
+}
+
+// RegisterJobs registers the email jobs with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.notifyJob = jobs.Register(q, JobNotify, 3, func(ctx context.Context, p NotifyJob) error {
+		return s.notify(p)
+	})
+	s.sendJob = jobs.Register(q, JobSend, 5, func(ctx context.Context, p SendJob) error {
+		return s.Send(p)
+	})
+}
+
+// Subscribe queues pack opened and upload closing emails for events
+// published on bus
+func (s *Service) Subscribe(bus *events.Bus) {
+	if !s.Enabled() {
+		return
+	}
+
+	bus.Subscribe(func(event events.Event) {
+		var kind string
+		switch event.Type {
+		case events.PackOpened:
+			kind = models.EmailPackOpened
+		case events.PackUploadsClosing:
+			kind = models.EmailUploadClosing
+		default:
+			return
+		}
+
+		data, ok := event.Data.(events.PackData)
+		if !ok {
+			return
+		}
+		key := fmt.Sprintf("%s:%d", kind, data.ID)
+		if _, err := s.notifyJob.EnqueueUnique(NotifyJob{Kind: kind, PackID: data.ID}, key); err != nil {
+			log.Printf("Failed to queue %s emails for pack %d: %v", kind, data.ID, err)
+		}
+	})
+}
+
+func (s *Service) notify(p NotifyJob) error {
+	var ids []uint
+	if err := subscribers(p.Kind).Pluck("users.id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range ids {
+		key := fmt.Sprintf("%s:%d:%d", p.Kind, p.PackID, userID)
+		if _, err := s.sendJob.EnqueueUnique(SendJob{UserID: userID, Kind: p.Kind, PackID: p.PackID}, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribers selects the users who want an email kind. Users who never
+// changed their preferences get every kind.
+func subscribers(kind string) *gorm.DB {
+	return db.GetDB().Model(&models.User{}).
+		Joins("LEFT JOIN email_preferences ON email_preferences.user_id = users.id").
+		Where("email_preferences.user_id IS NULL OR email_preferences."+kindColumns[kind]+" = ?", true)
+}
+
+// Send emails one user, unless they unsubscribed since it was queued
+func (s *Service) Send(p SendJob) error {
+	var user models.User
+	if err := db.GetDB().First(&user, p.UserID).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // deleted since
+		}
+		return err
+	}
+
+	prefs, err := s.Preferences(user.ID)
+	if err != nil {
+		return err
+	}
+	if !prefs.Wants(p.Kind) {
+		return nil
+	}
+
+	data := emailData{
+		Name:              user.Name,
+		UnsubscribeURL:    s.unsubscribeURL(prefs.UnsubscribeToken, p.Kind),
+		UnsubscribeAllURL: s.unsubscribeURL(prefs.UnsubscribeToken, UnsubscribeAll),
+	}
+	if data.Name == "" {
+		data.Name = "there"
+	}
+
+	var subject string
+	now := time.Now()
+	switch p.Kind {
+	case models.EmailPackOpened, models.EmailUploadClosing:
+		var pack models.SamplePack
+		if err := db.GetDB().First(&pack, p.PackID).Error; err != nil {
+			return err
+		}
+		data.Pack = &pack
+		data.PackURL = s.packURL(pack.ID)
+		if p.Kind == models.EmailPackOpened {
+			subject = pack.Title + " is open"
+		} else {
+			subject = "Uploads for " + pack.Title + " close in 24 hours"
+		}
+	case models.EmailWeeklyDigest:
+		// Recorded up front so a digest that keeps failing is not queued
+		// again every hour; the job's own retries still send it
+		if err := db.GetDB().Model(prefs).Update("last_digest_at", now).Error; err != nil {
+			return err
+		}
+		if err := s.digest(&data, now); err != nil {
+			return err
+		}
+		if data.Current == nil && data.NewSubmissions == 0 && len(data.Closed) == 0 {
+			return nil // nothing happened
+		}
+		subject = "Your week on quixit"
+	default:
+		return fmt.Errorf("unknown email kind %q", p.Kind)
+	}
+
+	body, err := render(p.Kind, data)
+	if err != nil {
+		return err
+	}
+
+	// Pack emails are claimed before sending, so that a send job queued
+	// again by a retried notify job finds the claim and stops
+	var sent *models.SentEmail
+	if p.Kind != models.EmailWeeklyDigest {
+		sent = &models.SentEmail{UserID: user.ID, Kind: p.Kind, PackID: p.PackID}
+		claim := db.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(sent)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return nil // already sent
+		}
+	}
+
+	err = send(s.cfg, &Message{
+		To:             user.Email,
+		Subject:        subject,
+		Body:           body,
+		UnsubscribeURL: data.UnsubscribeURL,
+	})
+	if err != nil && sent != nil {
+		// Release the claim so the retry sends it
+		if delErr := db.GetDB().Delete(sent).Error; delErr != nil {
+			log.Printf("Failed to release %s email claim for user %d: %v", p.Kind, p.UserID, delErr)
+		}
+	}
+	return err
+}
+
+func (s *Service) packURL(id uint) string {
+	return fmt.Sprintf("%s/packs/%d", s.publicURL, id)
+}
+
+func (s *Service) unsubscribeURL(token, kind string) string {
+	query := url.Values{"token": {token}, "kind": {kind}}
+	return s.publicURL + "/api/email/unsubscribe?" + query.Encode()
+}
+
+// Preferences returns the email preferences of a user, creating them with
+// every kind enabled on first use
+func (s *Service) Preferences(userID uint) (*models.EmailPreferences, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := &models.EmailPreferences{
+		UserID:           userID,
+		PackOpened:       true,
+		UploadClosing:    true,
+		WeeklyDigest:     true,
+		UnsubscribeToken: token,
+	}
+	if err := db.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(prefs).Error; err != nil {
+		return nil, err
+	}
+	if err := db.GetDB().First(prefs, userID).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// PreferencesInput holds changes to email preferences. Nil fields are left
+// unchanged.
+type PreferencesInput struct {
+	PackOpened    *bool `json:"packOpened"`
+	UploadClosing *bool `json:"uploadClosing"`
+	WeeklyDigest  *bool `json:"weeklyDigest"`
+}
+
+// UpdatePreferences changes the email preferences of a user
+func (s *Service) UpdatePreferences(userID uint, input PreferencesInput) (*models.EmailPreferences, error) {
+	prefs, err := s.Preferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if input.PackOpened != nil {
+		updates["pack_opened"] = *input.PackOpened
+	}
+	if input.UploadClosing != nil {
+		updates["upload_closing"] = *input.UploadClosing
+	}
+	if input.WeeklyDigest != nil {
+		updates["weekly_digest"] = *input.WeeklyDigest
+	}
+	if len(updates) == 0 {
+		return prefs, nil
+	}
+
+	if err := db.GetDB().Model(prefs).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return prefs, db.GetDB().First(prefs, userID).Error
+}
+
+// Unsubscribe turns off one kind of email, or every kind, for the user an
+// unsubscribe token belongs to
+func (s *Service) Unsubscribe(token, kind string) (*models.EmailPreferences, error) {
+	if token == "" {
+		return nil, errors.NewValidationError("token", "Unsubscribe token is required")
+	}
+
+	var updates map[string]interface{}
+	if kind == UnsubscribeAll || kind == "" {
+		updates = map[string]interface{}{}
+		for _, column := range kindColumns {
+			updates[column] = false
+		}
+	} else if column, ok := kindColumns[kind]; ok {
+		updates = map[string]interface{}{column: false}
+	} else {
+		return nil, errors.NewValidationError("kind", "Unknown email kind")
+	}
+
+	var prefs models.EmailPreferences
+	err := db.GetDB().Where("unsubscribe_token = ?", token).First(&prefs).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Unsubscribe link")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.GetDB().Model(&prefs).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &prefs, db.GetDB().First(&prefs, prefs.UserID).Error
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,59 @@
+package email
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+)
+
+const footer = `
+--
+You get this email because you have a quixit account.
+Unsubscribe from these emails: {{.UnsubscribeURL}}
+Unsubscribe from all quixit emails: {{.UnsubscribeAllURL}}
+`
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("Mon, 02 Jan 2006 15:04 MST") },
+}).Parse(`
+{{define "pack_opened"}}Hi {{.Name}},
+
+{{.Pack.Title}} is open! The samples are ready to download, and you can
+submit your track until {{date .Pack.EndDate}}.
+{{if .Pack.Description}}
+{{.Pack.Description}}
+{{end}}
+{{.PackURL}}
+` + footer + `{{end}}
+
+{{define "upload_closing"}}Hi {{.Name}},
+
+Sample uploads for {{.Pack.Title}} close in less than a day, on
+{{date .Pack.UploadEnd}}. Get your samples in before the pack opens!
+
+{{.PackURL}}
+` + footer + `{{end}}
+
+{{define "weekly_digest"}}Hi {{.Name}},
+
+Here is what happened on quixit this week.
+{{with .Current}}
+Current pack: {{.Pack.Title}}
+{{.Phase}}
+{{.Samples}} samples, {{.Submissions}} submissions so far.
+{{.URL}}
+{{end}}{{if .NewSubmissions}}
+{{.NewSubmissions}} new submissions were posted this week.
+{{end}}{{if .Closed}}
+Closed this week:
+{{range .Closed}}- {{.Pack.Title}}, {{.Submissions}} submissions: {{.URL}}
+{{end}}{{end}}` + footer + `{{end}}
+`))
+
+func render(name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
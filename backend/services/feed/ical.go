@@ -0,0 +1,141 @@
+package feed
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+)
+
+const icalTime = "20060102T150405Z"
+
+// calendarAlarms are the reminders set before each window closes
+var calendarAlarms = []struct {
+	before time.Duration
+	label  string
+}{
+	{24 * time.Hour, "24 hours"},
+	{time.Hour, "1 hour"},
+}
+
+// PackCalendar renders the upload and submission windows of current and
+// upcoming packs as an iCalendar feed
+func (s *Service) PackCalendar() ([]byte, error) {
+	var packs []models.SamplePack
+	if err := db.GetDB().
+		Where("end_date >= ?", time.Now()).
+		Order("upload_start").
+		Find(&packs).Error; err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	w := icalWriter{b: &b}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//quixit//pack windows//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:quixit pack windows")
+	w.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	w.line("X-PUBLISHED-TTL:PT1H")
+
+	stamp := time.Now().UTC().Format(icalTime)
+	for _, pack := range packs {
+		link := fmt.Sprintf("%s/packs/%d", s.cfg.PublicURL, pack.ID)
+		w.event(icalEvent{
+			uid:         fmt.Sprintf("pack-%d-upload@quixit", pack.ID),
+			stamp:       stamp,
+			modified:    pack.UpdatedAt,
+			start:       pack.UploadStart,
+			end:         pack.UploadEnd,
+			summary:     fmt.Sprintf("%s: sample upload window", pack.Title),
+			description: fmt.Sprintf("Upload samples for %s before the window closes.\n%s", pack.Title, link),
+			url:         link,
+			closing:     "Sample uploads for " + pack.Title,
+		})
+		w.event(icalEvent{
+			uid:         fmt.Sprintf("pack-%d-submission@quixit", pack.ID),
+			stamp:       stamp,
+			modified:    pack.UpdatedAt,
+			start:       pack.StartDate,
+			end:         pack.EndDate,
+			summary:     fmt.Sprintf("%s: track submission window", pack.Title),
+			description: fmt.Sprintf("Download the %s pack and submit your track before the window closes.\n%s", pack.Title, link),
+			url:         link,
+			closing:     "Track submissions for " + pack.Title,
+		})
+	}
+
+	w.line("END:VCALENDAR")
+	return []byte(b.String()), nil
+}
+
+type icalEvent struct {
+	uid         string
+	stamp       string
+	modified    time.Time
+	start       time.Time
+	end         time.Time
+	summary     string
+	description string
+	url         string
+	closing     string // what closes, used in alarm text
+}
+
+// icalWriter writes RFC 5545 content lines
+type icalWriter struct {
+	b *strings.Builder
+}
+
+func (w icalWriter) event(e icalEvent) {
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + e.uid)
+	w.line("DTSTAMP:" + e.stamp)
+	w.line("LAST-MODIFIED:" + e.modified.UTC().Format(icalTime))
+	w.line("DTSTART:" + e.start.UTC().Format(icalTime))
+	w.line("DTEND:" + e.end.UTC().Format(icalTime))
+	w.line("SUMMARY:" + icalEscape(e.summary))
+	w.line("DESCRIPTION:" + icalEscape(e.description))
+	w.line("URL:" + e.url)
+	w.line("TRANSP:TRANSPARENT")
+	for _, alarm := range calendarAlarms {
+		// Alarms are relative to the end so they warn before the window closes
+		w.line("BEGIN:VALARM")
+		w.line("ACTION:DISPLAY")
+		w.line(fmt.Sprintf("TRIGGER;RELATED=END:-PT%dM", int(alarm.before.Minutes())))
+		w.line("DESCRIPTION:" + icalEscape(fmt.Sprintf("%s close in %s", e.closing, alarm.label)))
+		w.line("END:VALARM")
+	}
+	w.line("END:VEVENT")
+}
+
+// line writes a content line, folding it at 75 octets without splitting
+// UTF-8 sequences
+func (w icalWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
+
+func icalEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,71 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// rss is an RSS 2.0 document with iTunes podcast extensions
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	ITunes  string   `xml:"xmlns:itunes,attr"`
+	Channel channel  `xml:"channel"`
+}
+
+type channel struct {
+	Title          string       `xml:"title"`
+	Link           string       `xml:"link"`
+	Description    string       `xml:"description"`
+	Language       string       `xml:"language"`
+	LastBuildDate  string       `xml:"lastBuildDate,omitempty"`
+	ITunesAuthor   string       `xml:"itunes:author"`
+	ITunesImage    *itunesImage `xml:"itunes:image,omitempty"`
+	ITunesExplicit string       `xml:"itunes:explicit"`
+	ITunesBlock    string       `xml:"itunes:block"`
+	Items          []item       `xml:"item"`
+}
+
+type item struct {
+	Title          string       `xml:"title"`
+	Description    string       `xml:"description,omitempty"`
+	GUID           guid         `xml:"guid"`
+	PubDate        string       `xml:"pubDate"`
+	Enclosure      enclosure    `xml:"enclosure"`
+	ITunesAuthor   string       `xml:"itunes:author"`
+	ITunesDuration string       `xml:"itunes:duration,omitempty"`
+	ITunesImage    *itunesImage `xml:"itunes:image,omitempty"`
+}
+
+type guid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type itunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+func newImage(href string) *itunesImage {
+	if href == "" {
+		return nil
+	}
+	return &itunesImage{Href: href}
+}
+
+// formatDuration formats seconds as HH:MM:SS for itunes:duration
+func formatDuration(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	total := int(seconds + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, total/60%60, total%60)
+}
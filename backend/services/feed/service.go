@@ -0,0 +1,209 @@
+package feed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/services/submission"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxItems is the most submissions a feed lists
+	MaxItems = 100
+
+	// globalPacks is how many recent packs the global feed covers
+	globalPacks = 10
+)
+
+type Service struct {
+	cfg               *config.Config
+	packService       *samplepack.Service
+	submissionService *submission.Service
+}
+
+func NewService(cfg *config.Config, packService *samplepack.Service, submissionService *submission.Service) *Service {
+	return &Service{
+		cfg:               cfg,
+		packService:       packService,
+		submissionService: submissionService,
+	}
+}
+
+// Token returns the feed token of a user, creating one on first use
+func (s *Service) Token(userID uint) (string, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if user.FeedToken != "" {
+		return user.FeedToken, nil
+	}
+	return s.RotateToken(userID)
+}
+
+// RotateToken replaces the feed token of a user, invalidating feed URLs
+// handed out before
+func (s *Service) RotateToken(userID uint) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if err := db.GetDB().Model(&models.User{}).Where("id = ?", userID).Update("feed_token", token).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate returns the user a feed token belongs to
+func (s *Service) Authenticate(token string) (*models.User, error) {
+	if token == "" {
+		return nil, errors.NewAuthenticationError("Feed token required")
+	}
+
+	var user models.User
+	if err := db.GetDB().Where("feed_token = ?", token).First(&user).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewAuthenticationError("Invalid feed token")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PackFeed renders the submissions of one pack as a podcast feed for user
+func (s *Service) PackFeed(packID uint, user *models.User) ([]byte, error) {
+	pack, err := s.packService.GetPack(packID)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.submissionService.ListSubmissionsFor(pack.ID, MaxItems, 0, viewerOf(user))
+	if err != nil {
+		return nil, err
+	}
+
+	ch := s.newChannel(
+		"quixit: "+pack.Title,
+		fmt.Sprintf("%s/packs/%d", s.cfg.PublicURL, pack.ID),
+		pack.Description,
+		pack.ArtworkURL,
+	)
+	if ch.Description == "" {
+		ch.Description = fmt.Sprintf("Tracks made from the %s sample pack", pack.Title)
+	}
+	return s.render(ch, submissions, user)
+}
+
+// GlobalFeed renders the latest submissions across recent packs as a
+// podcast feed for user
+func (s *Service) GlobalFeed(user *models.User) ([]byte, error) {
+	var packIDs []uint
+	if err := db.GetDB().Model(&models.SamplePack{}).
+		Order("created_at desc").
+		Limit(globalPacks).
+		Pluck("id", &packIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var submissions []models.Submission
+	for _, packID := range packIDs {
+		packSubmissions, err := s.submissionService.ListSubmissionsFor(packID, MaxItems, 0, viewerOf(user))
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, packSubmissions...)
+	}
+
+	sort.SliceStable(submissions, func(i, j int) bool {
+		return submissions[i].SubmittedAt.After(submissions[j].SubmittedAt)
+	})
+	if len(submissions) > MaxItems {
+		submissions = submissions[:MaxItems]
+	}
+
+	ch := s.newChannel("quixit", s.cfg.PublicURL+"/tracks", "Tracks made from quixit sample packs", "")
+	return s.render(ch, submissions, user)
+}
+
+func (s *Service) newChannel(title, link, description, artwork string) channel {
+	return channel{
+		Title:          title,
+		Link:           link,
+		Description:    description,
+		Language:       "en",
+		ITunesAuthor:   "quixit",
+		ITunesImage:    newImage(artwork),
+		ITunesExplicit: "false",
+		ITunesBlock:    "Yes", // feeds are private to each member
+	}
+}
+
+// render adds an item for every submission to ch and encodes the feed
+func (s *Service) render(ch channel, submissions []models.Submission, user *models.User) ([]byte, error) {
+	ch.Items = make([]item, 0, len(submissions))
+	for _, sub := range submissions {
+		ch.Items = append(ch.Items, s.newItem(sub, user.FeedToken))
+	}
+	if len(submissions) > 0 {
+		ch.LastBuildDate = submissions[0].SubmittedAt.Format(time.RFC1123Z)
+	}
+
+	out, err := xml.MarshalIndent(rss{Version: "2.0", ITunes: itunesNamespace, Channel: ch}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func (s *Service) newItem(sub models.Submission, token string) item {
+	title := sub.Title
+	if title == "" {
+		title = sub.Filename
+	}
+
+	author := "Anonymous"
+	if sub.User != nil && sub.User.Name != "" {
+		author = sub.User.Name
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(sub.Filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return item{
+		Title:       title,
+		Description: sub.Description,
+		GUID:        guid{Value: fmt.Sprintf("quixit-submission-%d", sub.ID)},
+		PubDate:     sub.SubmittedAt.Format(time.RFC1123Z),
+		Enclosure: enclosure{
+			URL:    fmt.Sprintf("%s/api/feeds/submissions/%d/audio?token=%s", s.cfg.PublicURL, sub.ID, url.QueryEscape(token)),
+			Length: sub.FileSize,
+			Type:   contentType,
+		},
+		ITunesAuthor:   author,
+		ITunesDuration: formatDuration(sub.Duration),
+		ITunesImage:    newImage(sub.SamplePack.ArtworkURL),
+	}
+}
+
+func viewerOf(user *models.User) samplepack.Viewer {
+	return samplepack.Viewer{UserID: user.ID, IsAdmin: user.IsAdmin}
+}
@@ -0,0 +1,146 @@
+package gc
+
+import (
+	stderrors "errors"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/storage"
+)
+
+// CorruptFile is a stored file whose contents no longer match its checksum
+type CorruptFile struct {
+	FileRef
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// ScrubReport lists the results of re-hashing every checksummed file
+type ScrubReport struct {
+	CheckedAt  time.Time     `json:"checkedAt"`
+	Verified   int           `json:"verified"`
+	Corrupt    []CorruptFile `json:"corrupt"`
+	Missing    []FileRef     `json:"missing"`
+	Unverified []FileRef     `json:"unverified"` // no checksum on record
+	Backfilled bool          `json:"backfilled"`
+	Errors     []string      `json:"errors,omitempty"`
+}
+
+// scrubTarget is a file to verify and the column holding its checksum
+type scrubTarget struct {
+	ref    FileRef
+	sum    string
+	model  interface{}
+	column string
+}
+
+// Scrub re-hashes samples, submissions and pack archives and compares them
+// with their recorded checksums. With backfill set, files without a checksum
+// have theirs recorded.
+func (s *Service) Scrub(backfill bool) (*ScrubReport, error) {
+	report := &ScrubReport{
+		CheckedAt:  time.Now(),
+		Corrupt:    []CorruptFile{},
+		Missing:    []FileRef{},
+		Unverified: []FileRef{},
+		Backfilled: backfill,
+	}
+
+	targets, err := scrubTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		s.scrub(report, target, backfill)
+	}
+	return report, nil
+}
+
+func scrubTargets() ([]scrubTarget, error) {
+	var targets []scrubTarget
+
+	var samples []models.Sample
+	if err := db.GetDB().Select("id", "file_path", "sha256").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	for _, sample := range samples {
+		targets = append(targets, scrubTarget{
+			ref:    FileRef{Kind: "sample", ID: sample.ID, Path: sample.FilePath},
+			sum:    sample.SHA256,
+			model:  &models.Sample{ID: sample.ID},
+			column: "sha256",
+		})
+	}
+
+	var submissions []models.Submission
+	if err := db.GetDB().Select("id", "file_path", "sha256").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		targets = append(targets, scrubTarget{
+			ref:    FileRef{Kind: "submission", ID: submission.ID, Path: submission.FilePath},
+			sum:    submission.SHA256,
+			model:  &models.Submission{ID: submission.ID},
+			column: "sha256",
+		})
+	}
+
+	var packs []models.SamplePack
+	if err := db.GetDB().Select("id", "archive_path", "archive_hash").Where("archive_path <> ''").Find(&packs).Error; err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		targets = append(targets, scrubTarget{
+			ref: FileRef{Kind: "archive", ID: pack.ID, Path: pack.ArchivePath},
+			sum: pack.ArchiveHash,
+		})
+	}
+
+	var variants []models.PackVariant
+	if err := db.GetDB().Select("id", "archive_path", "archive_hash").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	for _, variant := range variants {
+		targets = append(targets, scrubTarget{
+			ref: FileRef{Kind: "archive", ID: variant.ID, Path: variant.ArchivePath},
+			sum: variant.ArchiveHash,
+		})
+	}
+
+	return targets, nil
+}
+
+func (s *Service) scrub(report *ScrubReport, target scrubTarget, backfill bool) {
+	f, err := s.storage.Open(target.ref.Path)
+	if err != nil {
+		if stderrors.Is(err, storage.ErrNotExist) {
+			report.Missing = append(report.Missing, target.ref)
+		} else {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		return
+	}
+	defer f.Close()
+
+	sum, err := storage.Checksum(f)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return
+	}
+
+	switch {
+	case target.sum == "":
+		report.Unverified = append(report.Unverified, target.ref)
+		if backfill && target.model != nil {
+			if err := db.GetDB().Model(target.model).Update(target.column, sum).Error; err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+	case target.sum != sum:
+		report.Corrupt = append(report.Corrupt, CorruptFile{FileRef: target.ref, Expected: target.sum, Actual: sum})
+	default:
+		report.Verified++
+	}
+}
@@ -0,0 +1,334 @@
+package gc
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"time"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	JobCheckStorage = "storage.check"
+
+	// orphanGracePeriod protects files an upload has saved but not yet
+	// recorded in the database
+	orphanGracePeriod = time.Hour
+)
+
+// CheckJob is the payload of the periodic storage check
+type CheckJob struct {
+	Purge bool `json:"purge"`
+}
+
+// Service reconciles stored files with the rows that reference them
+type Service struct {
+	cfg      *config.Config
+	storage  storage.Storage
+	checkJob *jobs.JobType[CheckJob]
+}
+
+func NewService(cfg *config.Config, store storage.Storage) *Service {
+	return &Service{
+		cfg:     cfg,
+		storage: store,
+	}
+}
+
+// FileRef is a file referenced by a database row
+type FileRef struct {
+	Kind string `json:"kind"` // sample, submission, preview or archive
+	ID   uint   `json:"id"`
+	Path string `json:"path"`
+}
+
+// ExpiredRow is a soft-deleted row past the retention period
+type ExpiredRow struct {
+	Kind      string    `json:"kind"`
+	ID        uint      `json:"id"`
+	DeletedAt time.Time `json:"deletedAt"`
+	Files     []string  `json:"files"`
+}
+
+// Report lists the inconsistencies found by a check
+type Report struct {
+	CheckedAt     time.Time    `json:"checkedAt"`
+	FilesScanned  int          `json:"filesScanned"`
+	OrphanedFiles []string     `json:"orphanedFiles"`
+	OrphanedBytes int64        `json:"orphanedBytes"`
+	MissingFiles  []FileRef    `json:"missingFiles"`
+	ExpiredRows   []ExpiredRow `json:"expiredRows"`
+	Purged        bool         `json:"purged"`
+	Errors        []string     `json:"errors,omitempty"`
+}
+
+// RegisterJobs registers the periodic storage check with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.checkJob = jobs.Register(q, JobCheckStorage, 1, func(ctx context.Context, p CheckJob) error {
+		report, err := s.Check(p.Purge)
+		if err != nil {
+			return err
+		}
+		log.Printf("Storage check: %d files scanned, %d orphaned (%d bytes), %d missing, %d expired rows, purged=%t",
+			report.FilesScanned, len(report.OrphanedFiles), report.OrphanedBytes,
+			len(report.MissingFiles), len(report.ExpiredRows), report.Purged)
+		return nil
+	})
+}
+
+// RunPeriodicCheck queues a storage check every interval
+func (s *Service) RunPeriodicCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.checkJob.EnqueueUnique(CheckJob{Purge: s.cfg.GCPurge}, "storage"); err != nil {
+			log.Printf("Failed to queue storage check: %v", err)
+		}
+	}
+}
+
+// Check finds orphaned files, files missing for live rows, and soft-deleted
+// rows past the retention period. With purge set, orphaned files are deleted
+// and expired rows are removed along with their files.
+func (s *Service) Check(purge bool) (*Report, error) {
+	report := &Report{
+		CheckedAt:     time.Now(),
+		OrphanedFiles: []string{},
+		MissingFiles:  []FileRef{},
+		ExpiredRows:   []ExpiredRow{},
+		Purged:        purge,
+	}
+
+	refs, err := s.references(true)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.storage.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	report.FilesScanned = len(files)
+
+	referenced := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		referenced[filepath.Clean(ref.Path)] = true
+	}
+
+	// Replaced archives are kept for a grace period by their deletion job,
+	// however long ago they were written
+	retired, err := retiredArchives()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range retired {
+		referenced[filepath.Clean(path)] = true
+	}
+
+	for _, file := range files {
+		if referenced[filepath.Clean(file.Path)] || report.CheckedAt.Sub(file.ModTime) < orphanGracePeriod {
+			continue
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, file.Path)
+		report.OrphanedBytes += file.Size
+
+		if purge {
+			if err := s.storage.Delete(file.Path); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+	}
+
+	if err := s.findMissing(report); err != nil {
+		return nil, err
+	}
+	if err := s.findExpired(report, purge); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// retiredArchives returns the archives waiting for their deletion job
+func retiredArchives() ([]string, error) {
+	var pending []models.Job
+	if err := db.GetDB().Select("id", "payload").
+		Where("type = ? AND status IN ?", samplepack.JobDeleteArchive, []string{models.JobPending, models.JobRunning}).
+		Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(pending))
+	for _, job := range pending {
+		var payload samplepack.ArchiveFileJob
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			log.Printf("Skipping archive deletion job %d with bad payload: %v", job.ID, err)
+			continue
+		}
+		paths = append(paths, payload.Path)
+	}
+	return paths, nil
+}
+
+// references returns every file path stored in the database, including
+// those of soft-deleted rows that are still within retention when
+// includeDeleted is set
+func (s *Service) references(includeDeleted bool) ([]FileRef, error) {
+	scope := func() *gorm.DB {
+		if includeDeleted {
+			return db.GetDB().Unscoped()
+		}
+		return db.GetDB()
+	}
+	var refs []FileRef
+
+	var samples []models.Sample
+	if err := scope().Select("id", "file_path", "preview_path").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	for _, sample := range samples {
+		refs = appendRef(refs, "sample", sample.ID, sample.FilePath)
+		refs = appendRef(refs, "preview", sample.ID, sample.PreviewPath)
+	}
+
+	var submissions []models.Submission
+	if err := scope().Select("id", "file_path", "preview_path").Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		refs = appendRef(refs, "submission", submission.ID, submission.FilePath)
+		refs = appendRef(refs, "preview", submission.ID, submission.PreviewPath)
+	}
+
+	var packs []models.SamplePack
+	if err := scope().Select("id", "archive_path").Find(&packs).Error; err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		refs = appendRef(refs, "archive", pack.ID, pack.ArchivePath)
+	}
+
+	var variants []models.PackVariant
+	if err := db.GetDB().Select("id", "archive_path").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	for _, variant := range variants {
+		refs = appendRef(refs, "archive", variant.ID, variant.ArchivePath)
+	}
+
+	return refs, nil
+}
+
+func appendRef(refs []FileRef, kind string, id uint, path string) []FileRef {
+	if path == "" {
+		return refs
+	}
+	return append(refs, FileRef{Kind: kind, ID: id, Path: path})
+}
+
+// findMissing reports audio files of live samples and submissions that are
+// no longer in storage
+func (s *Service) findMissing(report *Report) error {
+	var samples []models.Sample
+	if err := db.GetDB().Select("id", "file_path").Find(&samples).Error; err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		s.checkExists(report, FileRef{Kind: "sample", ID: sample.ID, Path: sample.FilePath})
+	}
+
+	var submissions []models.Submission
+	if err := db.GetDB().Select("id", "file_path").Find(&submissions).Error; err != nil {
+		return err
+	}
+	for _, submission := range submissions {
+		s.checkExists(report, FileRef{Kind: "submission", ID: submission.ID, Path: submission.FilePath})
+	}
+	return nil
+}
+
+func (s *Service) checkExists(report *Report, ref FileRef) {
+	exists, err := s.storage.Exists(ref.Path)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return
+	}
+	if !exists {
+		report.MissingFiles = append(report.MissingFiles, ref)
+	}
+}
+
+// findExpired reports soft-deleted samples and submissions past retention,
+// deleting them and their files when purge is set. Files that a live row
+// also points to are kept.
+func (s *Service) findExpired(report *Report, purge bool) error {
+	cutoff := report.CheckedAt.Add(-s.cfg.GCRetention)
+
+	liveRefs, err := s.references(false)
+	if err != nil {
+		return err
+	}
+	live := make(map[string]bool, len(liveRefs))
+	for _, ref := range liveRefs {
+		live[filepath.Clean(ref.Path)] = true
+	}
+
+	var samples []models.Sample
+	if err := db.GetDB().Unscoped().Where("deleted_at < ?", cutoff).Find(&samples).Error; err != nil {
+		return err
+	}
+	for i := range samples {
+		sample := &samples[i]
+		s.expire(report, purge, live, sample, "sample", sample.ID, sample.DeletedAt.Time, sample.FilePath, sample.PreviewPath)
+	}
+
+	var submissions []models.Submission
+	if err := db.GetDB().Unscoped().Where("deleted_at < ?", cutoff).Find(&submissions).Error; err != nil {
+		return err
+	}
+	for i := range submissions {
+		submission := &submissions[i]
+		s.expire(report, purge, live, submission, "submission", submission.ID, submission.DeletedAt.Time, submission.FilePath, submission.PreviewPath)
+	}
+	return nil
+}
+
+func (s *Service) expire(report *Report, purge bool, live map[string]bool, row interface{}, kind string, id uint, deletedAt time.Time, paths ...string) {
+	expired := ExpiredRow{Kind: kind, ID: id, DeletedAt: deletedAt, Files: []string{}}
+	for _, path := range paths {
+		if path != "" {
+			expired.Files = append(expired.Files, path)
+		}
+	}
+	report.ExpiredRows = append(report.ExpiredRows, expired)
+
+	if !purge {
+		return
+	}
+
+	for _, path := range expired.Files {
+		if live[filepath.Clean(path)] {
+			continue
+		}
+		exists, err := s.storage.Exists(path)
+		if err == nil && exists {
+			err = s.storage.Delete(path)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return // keep the row so the files are retried next time
+		}
+	}
+	if err := db.GetDB().Unscoped().Delete(row).Error; err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+}
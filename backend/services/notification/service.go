@@ -0,0 +1,173 @@
+package notification
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const JobPackTracks = "notification.pack_tracks"
+
+// PackTracksJob is the payload of jobs that tell a closed pack's sample
+// contributors how many tracks were made from it
+type PackTracksJob struct {
+	PackID uint `json:"packId"`
+}
+
+type Service struct {
+	packTracksJob *jobs.JobType[PackTracksJob]
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+// RegisterJobs registers the notification jobs with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.packTracksJob = jobs.Register(q, JobPackTracks, 3, func(ctx context.Context, p PackTracksJob) error {
+		return s.NotifyPackTracks(p.PackID)
+	})
+}
+
+// Subscribe creates notifications for events published on bus
+func (s *Service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(func(event events.Event) {
+		switch data := event.Data.(type) {
+		case events.PackData:
+			if event.Type != events.PackClosed {
+				return
+			}
+			key := fmt.Sprintf("%d", data.ID)
+			if _, err := s.packTracksJob.EnqueueUnique(PackTracksJob{PackID: data.ID}, key); err != nil {
+				log.Printf("Failed to queue pack track notifications for pack %d: %v", data.ID, err)
+			}
+		case events.UploadRejectedData:
+			if err := s.NotifyUploadRejected(data); err != nil {
+				log.Printf("Failed to notify user %d of rejected %s %d: %v", data.UserID, data.Kind, data.ID, err)
+			}
+		}
+	})
+}
+
+// NotifyUploadRejected tells a user that their upload was removed for
+// exceeding its pack's loudness limits
+func (s *Service) NotifyUploadRejected(data events.UploadRejectedData) error {
+	key := fmt.Sprintf("%s:%s:%d", models.NotificationUploadRejected, data.Kind, data.ID)
+	return s.Create(&models.Notification{
+		UserID: data.UserID,
+		Type:   models.NotificationUploadRejected,
+		Title:  data.Filename + " was rejected",
+		Body:   "File rejected: " + data.Reason + ".",
+		Link:   fmt.Sprintf("/packs/%d", data.PackID),
+		Key:    &key,
+	})
+}
+
+// Create stores a notification. Notifications with a key are only stored
+// once per user.
+func (s *Service) Create(notification *models.Notification) error {
+	return db.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(notification).Error
+}
+
+// NotifyPackTracks tells everyone who contributed samples to a pack how
+// many tracks were submitted to it. Which samples a track used is not
+// recorded, so this is the pack's count, not the contributor's.
+func (s *Service) NotifyPackTracks(packID uint) error {
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, packID).Error; err != nil {
+		return err
+	}
+
+	var tracks int64
+	if err := db.GetDB().Model(&models.Submission{}).Where("sample_pack_id = ?", packID).Count(&tracks).Error; err != nil {
+		return err
+	}
+	if tracks == 0 {
+		return nil
+	}
+
+	var contributors []uint
+	if err := db.GetDB().Model(&models.Sample{}).Where("sample_pack_id = ?", packID).Distinct().Pluck("user_id", &contributors).Error; err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("%d tracks were made from %s.", tracks, pack.Title)
+	if tracks == 1 {
+		body = fmt.Sprintf("1 track was made from %s.", pack.Title)
+	}
+	key := fmt.Sprintf("%s:%d", models.NotificationPackTracks, packID)
+	for _, userID := range contributors {
+		err := s.Create(&models.Notification{
+			UserID: userID,
+			Type:   models.NotificationPackTracks,
+			Title:  pack.Title + " is closed",
+			Body:   body,
+			Link:   fmt.Sprintf("/packs/%d", packID),
+			Key:    &key,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns a user's notifications, newest first
+func (s *Service) List(userID uint, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	query := db.GetDB().Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&notifications).Error
+	return notifications, err
+}
+
+// UnreadCount returns how many notifications a user has not read
+func (s *Service) UnreadCount(userID uint) (int64, error) {
+	var count int64
+	err := db.GetDB().Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks one of a user's notifications as read
+func (s *Service) MarkRead(userID, id uint) (*models.Notification, error) {
+	var notification models.Notification
+	err := db.GetDB().Where("user_id = ?", userID).First(&notification, id).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Notification")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := db.GetDB().Model(&notification).Update("read_at", now).Error; err != nil {
+			return nil, err
+		}
+		notification.ReadAt = &now
+	}
+	return &notification, nil
+}
+
+// MarkAllRead marks every unread notification of a user as read and
+// returns how many there were
+func (s *Service) MarkAllRead(userID uint) (int64, error) {
+	result := db.GetDB().Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,117 @@
+package party
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 4096
+	sendBuffer     = 64
+)
+
+// client is one connection to a room
+type client struct {
+	room        *Room
+	conn        *websocket.Conn
+	send        chan []byte
+	participant Participant
+	canControl  bool // the host or an admin
+	dropped     bool // send is closed; guarded by the room lock
+
+	lastChat     time.Time
+	lastReaction time.Time
+}
+
+// sendMessage queues msg for this client only. Called with the room locked.
+func (c *client) sendMessage(msg serverMessage) {
+	if c.dropped {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+func (c *client) sendError(message string) {
+	c.sendMessage(serverMessage{Type: MsgError, Error: message})
+}
+
+// allow rate limits chat messages or reactions, whose last send time is at
+// last. Called with the room locked.
+func allow(last *time.Time) bool {
+	now := time.Now()
+	if now.Sub(*last) < minMessageDelay {
+		return false
+	}
+	*last = now
+	return true
+}
+
+// readPump applies incoming messages until the connection fails
+func (c *client) readPump() {
+	defer func() {
+		c.room.leave(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg clientMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.room.reject(c, "Invalid message")
+				continue
+			}
+			return
+		}
+		c.room.handle(c, msg)
+	}
+}
+
+// writePump writes queued messages and keeps the connection alive with
+// pings, until send is closed
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
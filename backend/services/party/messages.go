@@ -0,0 +1,120 @@
+package party
+
+import (
+	"time"
+
+	"sample-exchange/backend/models"
+)
+
+// Messages clients send
+const (
+	MsgPlay  = "play"  // host: {index?, position?}
+	MsgPause = "pause" // host: {position?}
+	MsgSeek  = "seek"  // host: {position}
+	MsgQueue = "queue" // host: {submissionIds}, empty to queue the whole pack
+	MsgChat  = "chat"  // {text}
+	MsgReact = "react" // {emoji}
+)
+
+// Messages the server sends
+const (
+	MsgState    = "state" // queue, playback or participants changed
+	MsgReaction = "reaction"
+	MsgError    = "error"
+	MsgClosed   = "closed" // the host closed the room
+)
+
+// QueueItem is a submission queued in a room. Authors of packs in blind
+// listening mode are hidden.
+type QueueItem struct {
+	SubmissionID uint    `json:"submissionId"`
+	Title        string  `json:"title"`
+	AuthorName   string  `json:"authorName,omitempty"`
+	Anonymous    bool    `json:"anonymous"`
+	Duration     float64 `json:"duration"`
+	FileURL      string  `json:"fileUrl"`
+	PreviewURL   string  `json:"previewUrl"`
+}
+
+func newQueueItem(submission *models.Submission) QueueItem {
+	item := QueueItem{
+		SubmissionID: submission.ID,
+		Title:        submission.Title,
+		Anonymous:    submission.Anonymous,
+		Duration:     submission.Duration,
+		FileURL:      submission.FileURL,
+		PreviewURL:   submission.PreviewURL,
+	}
+	if submission.User != nil {
+		item.AuthorName = submission.User.Name
+	}
+	return item
+}
+
+// Playback is what the room is playing. While Playing, clients add the time
+// since UpdatedAt to Position.
+type Playback struct {
+	Index     int       `json:"index"` // into the queue, -1 before anything played
+	Playing   bool      `json:"playing"`
+	Position  float64   `json:"position"` // seconds into the track at UpdatedAt
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// position returns where playback is at now
+func (p Playback) position(now time.Time) float64 {
+	if !p.Playing {
+		return p.Position
+	}
+	return p.Position + now.Sub(p.UpdatedAt).Seconds()
+}
+
+// Participant is someone connected to a room
+type Participant struct {
+	UserID uint   `json:"userId"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// State is everything a client needs to render a room
+type State struct {
+	RoomID       uint          `json:"roomId"`
+	HostID       uint          `json:"hostId"`
+	Queue        []QueueItem   `json:"queue"`
+	Playback     Playback      `json:"playback"`
+	Participants []Participant `json:"participants"`
+	ServerTime   time.Time     `json:"serverTime"` // lets clients correct for clock skew
+}
+
+// ChatMessage is a message in the room chat
+type ChatMessage struct {
+	Participant
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// Reaction is an emoji sent at a point in the current track
+type Reaction struct {
+	Participant
+	Emoji    string    `json:"emoji"`
+	Index    int       `json:"index"`
+	Position float64   `json:"position"`
+	Time     time.Time `json:"time"`
+}
+
+type clientMessage struct {
+	Type          string   `json:"type"`
+	Index         *int     `json:"index,omitempty"`
+	Position      *float64 `json:"position,omitempty"`
+	SubmissionIDs []uint   `json:"submissionIds,omitempty"`
+	Text          string   `json:"text,omitempty"`
+	Emoji         string   `json:"emoji,omitempty"`
+}
+
+type serverMessage struct {
+	Type     string        `json:"type"`
+	State    *State        `json:"state,omitempty"`
+	Chat     *ChatMessage  `json:"chat,omitempty"`
+	History  []ChatMessage `json:"history,omitempty"` // recent chat, sent on joining
+	Reaction *Reaction     `json:"reaction,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
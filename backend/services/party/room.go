@@ -0,0 +1,325 @@
+package party
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	chatHistory     = 50  // messages sent to people joining
+	maxChatLength   = 500 // runes
+	maxEmojiLength  = 8   // runes
+	minMessageDelay = 300 * time.Millisecond
+)
+
+// Room is the live state of a listening room. Every change is broadcast
+// to all connected clients.
+type Room struct {
+	id     uint
+	hostID uint
+	load   func() ([]QueueItem, error) // the pack's submissions, oldest first
+
+	mu       sync.Mutex
+	queue    []QueueItem
+	playback Playback
+	clients  map[*client]struct{}
+	history  []ChatMessage
+	closed   bool
+}
+
+func newRoom(id, hostID uint, load func() ([]QueueItem, error)) (*Room, error) {
+	queue, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Room{
+		id:       id,
+		hostID:   hostID,
+		load:     load,
+		queue:    queue,
+		playback: Playback{Index: -1, UpdatedAt: time.Now()},
+		clients:  make(map[*client]struct{}),
+	}, nil
+}
+
+// join adds a client, sending it the room state and recent chat
+func (r *Room) join(c *client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+
+	r.clients[c] = struct{}{}
+	state := r.state()
+	c.sendMessage(serverMessage{Type: MsgState, State: state, History: r.history})
+	r.broadcastTo(serverMessage{Type: MsgState, State: state}, c)
+	return true
+}
+
+// leave removes a client, closing its connection
+func (r *Room) leave(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[c]; !ok {
+		return
+	}
+
+	r.drop(c)
+	r.broadcastState()
+}
+
+// drop removes a client and closes its send channel. Its readPump keeps
+// running until the connection closes, so dropped stops anything further
+// being sent on the closed channel.
+func (r *Room) drop(c *client) {
+	delete(r.clients, c)
+	if !c.dropped {
+		c.dropped = true
+		close(c.send)
+	}
+}
+
+// reject answers a message that could not be read
+func (r *Room) reject(c *client, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.sendError(message)
+}
+
+// close disconnects everyone after telling them the room closed
+func (r *Room) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	r.closed = true
+	r.broadcast(serverMessage{Type: MsgClosed})
+	for c := range r.clients {
+		r.drop(c)
+	}
+}
+
+func (r *Room) participants() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.participantList())
+}
+
+// handle applies a message from a client
+func (r *Room) handle(c *client, msg clientMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	switch msg.Type {
+	case MsgPlay, MsgPause, MsgSeek, MsgQueue:
+		if !c.canControl {
+			c.sendError("Only the host controls playback")
+			return
+		}
+		if err := r.control(msg); err != "" {
+			c.sendError(err)
+			return
+		}
+		r.broadcastState()
+	case MsgChat:
+		text := strings.TrimSpace(msg.Text)
+		if text == "" || utf8.RuneCountInString(text) > maxChatLength {
+			c.sendError("Messages must be 1 to 500 characters")
+			return
+		}
+		if !allow(&c.lastChat) {
+			c.sendError("You are sending messages too quickly")
+			return
+		}
+		chat := ChatMessage{Participant: c.participant, Text: text, Time: time.Now()}
+		r.history = append(r.history, chat)
+		if len(r.history) > chatHistory {
+			r.history = r.history[len(r.history)-chatHistory:]
+		}
+		r.broadcast(serverMessage{Type: MsgChat, Chat: &chat})
+	case MsgReact:
+		emoji := strings.TrimSpace(msg.Emoji)
+		if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiLength {
+			c.sendError("Invalid reaction")
+			return
+		}
+		if !allow(&c.lastReaction) {
+			c.sendError("You are sending reactions too quickly")
+			return
+		}
+		now := time.Now()
+		r.broadcast(serverMessage{Type: MsgReaction, Reaction: &Reaction{
+			Participant: c.participant,
+			Emoji:       emoji,
+			Index:       r.playback.Index,
+			Position:    r.playback.position(now),
+			Time:        now,
+		}})
+	default:
+		c.sendError("Unknown message type")
+	}
+}
+
+// control applies a host message to the playback or queue, returning an
+// error message for invalid ones
+func (r *Room) control(msg clientMessage) string {
+	now := time.Now()
+	playback := r.playback
+
+	switch msg.Type {
+	case MsgPlay:
+		if msg.Index != nil && *msg.Index != playback.Index {
+			playback.Index = *msg.Index
+			playback.Position = 0
+		} else {
+			playback.Position = playback.position(now)
+		}
+		if playback.Index < 0 {
+			playback.Index = 0
+		}
+		if msg.Position != nil {
+			playback.Position = *msg.Position
+		}
+		playback.Playing = true
+	case MsgPause:
+		playback.Position = playback.position(now)
+		if msg.Position != nil {
+			playback.Position = *msg.Position
+		}
+		playback.Playing = false
+	case MsgSeek:
+		if msg.Position == nil {
+			return "Seeking needs a position"
+		}
+		playback.Position = *msg.Position
+	case MsgQueue:
+		queue, err := r.buildQueue(msg.SubmissionIDs)
+		if err != "" {
+			return err
+		}
+		playback.Position = playback.position(now)
+		playback.Index = indexOf(queue, r.current())
+		if playback.Index < 0 {
+			playback.Playing = false
+			playback.Position = 0
+		}
+		r.queue = queue
+	}
+
+	if playback.Index >= len(r.queue) {
+		return "No such track in the queue"
+	}
+	if playback.Position < 0 {
+		return "Position cannot be negative"
+	}
+	playback.UpdatedAt = now
+	r.playback = playback
+	return ""
+}
+
+// buildQueue orders the pack's current submissions by ids, or returns all
+// of them when ids is empty
+func (r *Room) buildQueue(ids []uint) ([]QueueItem, string) {
+	items, err := r.load()
+	if err != nil {
+		log.Printf("Failed to load submissions for room %d: %v", r.id, err)
+		return nil, "Failed to load submissions"
+	}
+	if len(ids) == 0 {
+		return items, ""
+	}
+
+	byID := make(map[uint]QueueItem, len(items))
+	for _, item := range items {
+		byID[item.SubmissionID] = item
+	}
+	queue := make([]QueueItem, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			return nil, "Only submissions to the room's pack can be queued"
+		}
+		queue = append(queue, item)
+	}
+	return queue, ""
+}
+
+// current returns the ID of the playing submission, or 0
+func (r *Room) current() uint {
+	if r.playback.Index < 0 || r.playback.Index >= len(r.queue) {
+		return 0
+	}
+	return r.queue[r.playback.Index].SubmissionID
+}
+
+func indexOf(queue []QueueItem, submissionID uint) int {
+	for i, item := range queue {
+		if item.SubmissionID == submissionID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Room) state() *State {
+	return &State{
+		RoomID:       r.id,
+		HostID:       r.hostID,
+		Queue:        r.queue,
+		Playback:     r.playback,
+		Participants: r.participantList(),
+		ServerTime:   time.Now(),
+	}
+}
+
+// participantList lists everyone connected once, however many tabs they
+// have open
+func (r *Room) participantList() []Participant {
+	seen := make(map[uint]bool)
+	participants := []Participant{}
+	for c := range r.clients {
+		if !seen[c.participant.UserID] {
+			seen[c.participant.UserID] = true
+			participants = append(participants, c.participant)
+		}
+	}
+	return participants
+}
+
+func (r *Room) broadcastState() {
+	r.broadcast(serverMessage{Type: MsgState, State: r.state()})
+}
+
+// broadcast sends msg to every client, dropping clients that cannot keep up
+func (r *Room) broadcast(msg serverMessage) {
+	r.broadcastTo(msg, nil)
+}
+
+// broadcastTo sends msg to every client but skip
+func (r *Room) broadcastTo(msg serverMessage, skip *client) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to encode room message: %v", err)
+		return
+	}
+	for c := range r.clients {
+		if c == skip {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			r.drop(c)
+		}
+	}
+}
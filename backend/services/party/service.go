@@ -0,0 +1,197 @@
+package party
+
+import (
+	stderrors "errors"
+	"strings"
+	"sync"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/services/submission"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// maxQueue is the most submissions a room loads from its pack
+const maxQueue = 500
+
+// Service manages listening rooms. Rooms are stored so they can be listed
+// and linked to, while their playback, chat and connections live in memory.
+type Service struct {
+	packService       *samplepack.Service
+	submissionService *submission.Service
+
+	mu    sync.Mutex
+	rooms map[uint]*Room
+}
+
+func NewService(packService *samplepack.Service, submissionService *submission.Service) *Service {
+	return &Service{
+		packService:       packService,
+		submissionService: submissionService,
+		rooms:             make(map[uint]*Room),
+	}
+}
+
+// Create opens a listening room for a pack's submissions, hosted by host.
+// The pack must be open.
+func (s *Service) Create(host samplepack.Viewer, packID uint, title string) (*models.ListeningRoom, error) {
+	pack, err := s.packService.GetPackFor(packID, host)
+	if err != nil {
+		return nil, err
+	}
+	if !s.packService.IsPackOpen(pack) {
+		return nil, errors.NewValidationError("pack", "Pack is not open yet")
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = pack.Title + " listening party"
+	}
+
+	room := &models.ListeningRoom{
+		Title:        title,
+		HostID:       host.UserID,
+		SamplePackID: pack.ID,
+	}
+	if err := db.GetDB().Create(room).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(room.ID)
+}
+
+// Get returns an open room
+func (s *Service) Get(id uint) (*models.ListeningRoom, error) {
+	var room models.ListeningRoom
+	err := db.GetDB().Preload("Host").Preload("SamplePack").
+		Where("closed_at IS NULL").
+		First(&room, id).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Room")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	room.Participants = s.participants(room.ID)
+	return &room, nil
+}
+
+// List returns the open rooms, newest first
+func (s *Service) List() ([]models.ListeningRoom, error) {
+	var rooms []models.ListeningRoom
+	err := db.GetDB().Preload("Host").Preload("SamplePack").
+		Where("closed_at IS NULL").
+		Order("created_at DESC").
+		Find(&rooms).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rooms {
+		rooms[i].Participants = s.participants(rooms[i].ID)
+	}
+	return rooms, nil
+}
+
+// Close ends a room, disconnecting everyone. Only its host or an admin may
+// close it.
+func (s *Service) Close(id uint, viewer samplepack.Viewer) error {
+	room, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if room.HostID != viewer.UserID && !viewer.IsAdmin {
+		return errors.NewAuthorizationError("Only the host can close the room")
+	}
+
+	if err := db.GetDB().Model(room).Update("closed_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	live := s.rooms[id]
+	delete(s.rooms, id)
+	s.mu.Unlock()
+	if live != nil {
+		live.close()
+	}
+	return nil
+}
+
+// Serve connects a participant to a room and relays messages until the
+// connection closes
+func (s *Service) Serve(room *models.ListeningRoom, conn *websocket.Conn, viewer samplepack.Viewer) error {
+	var user models.User
+	if err := db.GetDB().First(&user, viewer.UserID).Error; err != nil {
+		conn.Close()
+		return err
+	}
+
+	live, err := s.live(room)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c := &client{
+		room:        live,
+		conn:        conn,
+		send:        make(chan []byte, sendBuffer),
+		participant: Participant{UserID: user.ID, Name: user.Name, Avatar: user.Avatar},
+		canControl:  room.HostID == user.ID || viewer.IsAdmin,
+	}
+	if !live.join(c) {
+		conn.Close()
+		return errors.NewNotFoundError("Room")
+	}
+
+	go c.writePump()
+	c.readPump()
+	return nil
+}
+
+// live returns the in-memory state of a room, starting it on first use
+func (s *Service) live(room *models.ListeningRoom) (*Room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if live, ok := s.rooms[room.ID]; ok {
+		return live, nil
+	}
+
+	packID := room.SamplePackID
+	live, err := newRoom(room.ID, room.HostID, func() ([]QueueItem, error) {
+		// Listed as a guest so blind listening hides authors from everyone
+		submissions, err := s.submissionService.ListSubmissionsFor(packID, maxQueue, 0, samplepack.Viewer{})
+		if err != nil {
+			return nil, err
+		}
+		queue := make([]QueueItem, len(submissions))
+		for i := range submissions {
+			// Listed newest first, played in the order they came in
+			queue[len(submissions)-1-i] = newQueueItem(&submissions[i])
+		}
+		return queue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.rooms[room.ID] = live
+	return live, nil
+}
+
+func (s *Service) participants(id uint) int {
+	s.mu.Lock()
+	live := s.rooms[id]
+	s.mu.Unlock()
+	if live == nil {
+		return 0
+	}
+	return live.participants()
+}
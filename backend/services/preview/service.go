@@ -0,0 +1,140 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/storage"
+)
+
+const (
+	JobSamplePreview     = "preview.sample"
+	JobSubmissionPreview = "preview.submission"
+)
+
+// PreviewJob is the payload of preview transcoding jobs
+type PreviewJob struct {
+	ID uint `json:"id"`
+}
+
+type Service struct {
+	storage storage.Storage
+
+	sampleJob     *jobs.JobType[PreviewJob]
+	submissionJob *jobs.JobType[PreviewJob]
+}
+
+func NewService(store storage.Storage) *Service {
+	return &Service{
+		storage: store,
+	}
+}
+
+// GenerateSamplePreview transcodes a sample into its preview rendition
+func (s *Service) GenerateSamplePreview(ctx context.Context, id uint) error {
+	var sample models.Sample
+	if err := db.GetDB().First(&sample, id).Error; err != nil {
+		return err
+	}
+
+	path, err := s.generate(ctx, sample.FilePath, fmt.Sprintf("sample_%d%s", sample.ID, audio.PreviewExtension))
+	return s.record(&sample, sample.PreviewPath, path, err)
+}
+
+// GenerateSubmissionPreview transcodes a submission into its preview rendition
+func (s *Service) GenerateSubmissionPreview(ctx context.Context, id uint) error {
+	var submission models.Submission
+	if err := db.GetDB().First(&submission, id).Error; err != nil {
+		return err
+	}
+
+	path, err := s.generate(ctx, submission.FilePath, fmt.Sprintf("submission_%d%s", submission.ID, audio.PreviewExtension))
+	return s.record(&submission, submission.PreviewPath, path, err)
+}
+
+// RegisterJobs registers preview transcoding jobs with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.sampleJob = jobs.Register(q, JobSamplePreview, jobs.DefaultMaxAttempts, func(ctx context.Context, p PreviewJob) error {
+		return s.GenerateSamplePreview(ctx, p.ID)
+	})
+	s.submissionJob = jobs.Register(q, JobSubmissionPreview, jobs.DefaultMaxAttempts, func(ctx context.Context, p PreviewJob) error {
+		return s.GenerateSubmissionPreview(ctx, p.ID)
+	})
+}
+
+// QueueSamplePreview generates a sample preview in the background
+func (s *Service) QueueSamplePreview(id uint) {
+	if _, err := s.sampleJob.Enqueue(PreviewJob{ID: id}); err != nil {
+		log.Printf("Failed to queue preview for sample %d: %v", id, err)
+	}
+}
+
+// QueueSubmissionPreview generates a submission preview in the background
+func (s *Service) QueueSubmissionPreview(id uint) {
+	if _, err := s.submissionJob.Enqueue(PreviewJob{ID: id}); err != nil {
+		log.Printf("Failed to queue preview for submission %d: %v", id, err)
+	}
+}
+
+// generate transcodes the stored file src to a temporary file and stores it
+// as a preview
+func (s *Service) generate(ctx context.Context, src, name string) (string, error) {
+	srcPath, release, err := s.storage.LocalPath(src)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	tmpDir, err := os.MkdirTemp("", "preview_*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, name)
+	if err := audio.TranscodePreview(ctx, srcPath, tmpPath); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stored, err := s.storage.SavePreview(f, name)
+	if err != nil {
+		return "", err
+	}
+	return stored.Path, nil
+}
+
+// record stores the outcome of a preview job on model
+func (s *Service) record(model interface{}, oldPath, path string, genErr error) error {
+	if genErr != nil {
+		if err := db.GetDB().Model(model).Update("preview_status", models.PreviewFailed).Error; err != nil {
+			log.Printf("Failed to record preview failure: %v", err)
+		}
+		return genErr
+	}
+
+	if err := db.GetDB().Model(model).Updates(map[string]interface{}{
+		"preview_path":   path,
+		"preview_status": models.PreviewReady,
+	}).Error; err != nil {
+		s.storage.Delete(path) // Clean up on error
+		return err
+	}
+
+	if oldPath != "" && oldPath != path {
+		s.storage.Delete(oldPath)
+	}
+	return nil
+}
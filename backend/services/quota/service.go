@@ -0,0 +1,285 @@
+package quota
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"sample-exchange/backend/config"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Service struct {
+	cfg *config.Config
+}
+
+func NewService(cfg *config.Config) *Service {
+	return &Service{
+		cfg: cfg,
+	}
+}
+
+// Usage is the number and total size of files a user has uploaded
+type Usage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Allowance pairs a user's usage with the limit that applies to it. Nil
+// limits are unlimited.
+type Allowance struct {
+	Usage Usage        `json:"usage"`
+	Limit models.Quota `json:"limit"`
+}
+
+// PackAllowance is a user's allowance for samples in one pack
+type PackAllowance struct {
+	PackID uint   `json:"packID"`
+	Title  string `json:"title"`
+	Allowance
+}
+
+// Report summarizes a user's usage across all packs and per pack
+type Report struct {
+	Total Allowance       `json:"total"`
+	Packs []PackAllowance `json:"packs"`
+}
+
+// GetReport returns a user's current usage and limits, covering the active
+// pack and every pack the user has uploaded samples to
+func (s *Service) GetReport(userID uint) (*Report, error) {
+	override, err := s.GetUserQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.totalUsage(db.GetDB(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var packs []models.SamplePack
+	if err := db.GetDB().
+		Where("is_active = ? OR id IN (?)", true,
+			db.GetDB().Model(&models.Sample{}).Select("sample_pack_id").Where("user_id = ?", userID)).
+		Order("id DESC").
+		Find(&packs).Error; err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Total: Allowance{Usage: *total, Limit: s.totalLimit(override)},
+		Packs: make([]PackAllowance, 0, len(packs)),
+	}
+	for _, pack := range packs {
+		usage, err := s.packUsage(db.GetDB(), userID, pack.ID)
+		if err != nil {
+			return nil, err
+		}
+		report.Packs = append(report.Packs, PackAllowance{
+			PackID:    pack.ID,
+			Title:     pack.Title,
+			Allowance: Allowance{Usage: *usage, Limit: s.packLimit(override, &pack)},
+		})
+	}
+	return report, nil
+}
+
+// CheckSample returns an authorization error if adding a sample of size
+// bytes to a pack would exceed the user's quotas. Use it to turn uploads
+// away early; only CheckSampleTx is final.
+func (s *Service) CheckSample(userID, packID uint, size int64) error {
+	return s.checkSample(db.GetDB(), userID, packID, size)
+}
+
+// CheckSampleTx is CheckSample within tx, the transaction that adds the
+// sample. It locks the user's row until tx ends, so concurrent uploads by
+// the same user are checked one at a time.
+func (s *Service) CheckSampleTx(tx *gorm.DB, userID, packID uint, size int64) error {
+	if err := lockUser(tx, userID); err != nil {
+		return err
+	}
+	return s.checkSample(tx, userID, packID, size)
+}
+
+func (s *Service) checkSample(conn *gorm.DB, userID, packID uint, size int64) error {
+	override, err := s.getUserQuota(conn, userID)
+	if err != nil {
+		return err
+	}
+
+	var pack models.SamplePack
+	if err := conn.First(&pack, packID).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.NewNotFoundError("Sample pack")
+		}
+		return err
+	}
+
+	usage, err := s.packUsage(conn, userID, packID)
+	if err != nil {
+		return err
+	}
+	if err := check(*usage, s.packLimit(override, &pack), size, "this pack"); err != nil {
+		return err
+	}
+
+	return s.checkTotal(conn, userID, override, size)
+}
+
+// CheckSubmission returns an authorization error if adding a submission of
+// size bytes would exceed the user's quotas. Use it to turn uploads away
+// early; only CheckSubmissionTx is final.
+func (s *Service) CheckSubmission(userID uint, size int64) error {
+	return s.checkSubmission(db.GetDB(), userID, size)
+}
+
+// CheckSubmissionTx is CheckSubmission within tx, the transaction that
+// creates the submission. It locks the user's row until tx ends, so
+// concurrent uploads by the same user are checked one at a time.
+func (s *Service) CheckSubmissionTx(tx *gorm.DB, userID uint, size int64) error {
+	if err := lockUser(tx, userID); err != nil {
+		return err
+	}
+	return s.checkSubmission(tx, userID, size)
+}
+
+func (s *Service) checkSubmission(conn *gorm.DB, userID uint, size int64) error {
+	override, err := s.getUserQuota(conn, userID)
+	if err != nil {
+		return err
+	}
+	return s.checkTotal(conn, userID, override, size)
+}
+
+// lockUser locks a user's row for the rest of tx. Samples and submissions
+// both count towards the user's total, so every upload takes this lock.
+func lockUser(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error
+}
+
+func (s *Service) checkTotal(conn *gorm.DB, userID uint, override *models.UserQuota, size int64) error {
+	usage, err := s.totalUsage(conn, userID)
+	if err != nil {
+		return err
+	}
+	return check(*usage, s.totalLimit(override), size, "your account")
+}
+
+func check(usage Usage, limit models.Quota, size int64, scope string) error {
+	if limit.MaxFiles != nil && usage.Files+1 > *limit.MaxFiles {
+		return errors.NewAuthorizationError(fmt.Sprintf("Upload quota exceeded: at most %d files allowed for %s", *limit.MaxFiles, scope))
+	}
+	if limit.MaxBytes != nil && usage.Bytes+size > *limit.MaxBytes {
+		return errors.NewAuthorizationError(fmt.Sprintf("Upload quota exceeded: at most %d MB allowed for %s", *limit.MaxBytes/(1024*1024), scope))
+	}
+	return nil
+}
+
+// GetUserQuota returns the admin override for a user, which is empty if none
+// has been set
+func (s *Service) GetUserQuota(userID uint) (*models.UserQuota, error) {
+	return s.getUserQuota(db.GetDB(), userID)
+}
+
+func (s *Service) getUserQuota(conn *gorm.DB, userID uint) (*models.UserQuota, error) {
+	quota := &models.UserQuota{UserID: userID}
+	err := conn.Where("user_id = ?", userID).First(quota).Error
+	if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// SetUserQuota replaces the admin override for a user
+func (s *Service) SetUserQuota(userID uint, quota models.UserQuota) (*models.UserQuota, error) {
+	var user models.User
+	if err := db.GetDB().First(&user, userID).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewNotFoundError("User")
+		}
+		return nil, err
+	}
+
+	quota.UserID = userID
+	if err := db.GetDB().Save(&quota).Error; err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// DeleteUserQuota removes the admin override for a user
+func (s *Service) DeleteUserQuota(userID uint) error {
+	return db.GetDB().Where("user_id = ?", userID).Delete(&models.UserQuota{}).Error
+}
+
+func (s *Service) totalLimit(override *models.UserQuota) models.Quota {
+	return resolve(override.Total, models.Quota{}, s.cfg.UserQuotaFiles, s.cfg.UserQuotaBytes)
+}
+
+func (s *Service) packLimit(override *models.UserQuota, pack *models.SamplePack) models.Quota {
+	return resolve(override.PerPack, pack.UploadQuota, s.cfg.PackQuotaFiles, s.cfg.PackQuotaBytes)
+}
+
+// resolve picks the first set limit of override, then fallback, then the
+// configured default, returning nil for unlimited
+func resolve(override, fallback models.Quota, defaultFiles int, defaultBytes int64) models.Quota {
+	files := &defaultFiles
+	if fallback.MaxFiles != nil {
+		files = fallback.MaxFiles
+	}
+	if override.MaxFiles != nil {
+		files = override.MaxFiles
+	}
+
+	bytes := &defaultBytes
+	if fallback.MaxBytes != nil {
+		bytes = fallback.MaxBytes
+	}
+	if override.MaxBytes != nil {
+		bytes = override.MaxBytes
+	}
+
+	var limit models.Quota
+	if *files >= 0 {
+		limit.MaxFiles = files
+	}
+	if *bytes >= 0 {
+		limit.MaxBytes = bytes
+	}
+	return limit
+}
+
+func (s *Service) packUsage(conn *gorm.DB, userID, packID uint) (*Usage, error) {
+	var usage Usage
+	err := conn.Model(&models.Sample{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("user_id = ? AND sample_pack_id = ?", userID, packID).
+		Scan(&usage).Error
+	return &usage, err
+}
+
+func (s *Service) totalUsage(conn *gorm.DB, userID uint) (*Usage, error) {
+	var samples, submissions Usage
+	if err := conn.Model(&models.Sample{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("user_id = ?", userID).
+		Scan(&samples).Error; err != nil {
+		return nil, err
+	}
+	if err := conn.Model(&models.Submission{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("user_id = ?", userID).
+		Scan(&submissions).Error; err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		Files: samples.Files + submissions.Files,
+		Bytes: samples.Bytes + submissions.Bytes,
+	}, nil
+}
@@ -0,0 +1,152 @@
+package samplepack
+
+import (
+	"context"
+	"log"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AnalysisPending = "pending"
+	AnalysisDone    = "done"
+	AnalysisFailed  = "failed"
+	AnalysisSkipped = "skipped"
+
+	BPMSourceUser     = "user"
+	BPMSourceAnalysis = "analysis"
+	KeySourceUser     = "user"
+	KeySourceAnalysis = "analysis"
+)
+
+// analyzableFormats lists the lossless formats tempo and key estimation runs on
+var analyzableFormats = map[string]bool{
+	"wav":  true,
+	"aiff": true,
+	"flac": true,
+}
+
+// AnalyzeSample measures the loudness of a new sample, removing it if it
+// exceeds its pack's limits, then estimates its tempo and key and stores the
+// results. Estimates only replace the sample's BPM and key when the
+// uploader did not supply them.
+func (s *Service) AnalyzeSample(ctx context.Context, sampleID uint) error {
+	var sample models.Sample
+	if err := db.GetDB().First(&sample, sampleID).Error; err != nil {
+		return err
+	}
+
+	if sample.LoudnessStatus == models.LoudnessPending {
+		rejected, err := s.checkSampleLoudness(ctx, &sample)
+		if err != nil || rejected {
+			return err
+		}
+	}
+
+	if !analyzableFormats[sample.Format] {
+		return db.GetDB().Model(&sample).Update("analysis_status", AnalysisSkipped).Error
+	}
+
+	path, release, err := s.storage.LocalPath(sample.FilePath)
+	if err != nil {
+		db.GetDB().Model(&sample).Update("analysis_status", AnalysisFailed)
+		return err
+	}
+	buf, err := audio.Decode(ctx, path)
+	release()
+	if err != nil {
+		db.GetDB().Model(&sample).Update("analysis_status", AnalysisFailed)
+		return err
+	}
+	result := audio.Analyze(buf)
+
+	log.Printf("Analyzed sample %d: %.1f BPM (%.2f), %s (%.2f)",
+		sample.ID, result.BPM, result.BPMConfidence, result.Key, result.KeyConfidence)
+
+	// The uploader may override the BPM or key while analysis runs, so the
+	// estimates only replace values the uploader has not set at write time
+	return db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&sample).Updates(map[string]interface{}{
+			"analysis_status": AnalysisDone,
+			"estimated_bpm":   result.BPM,
+			"bpm_confidence":  result.BPMConfidence,
+			"estimated_key":   result.Key,
+			"key_confidence":  result.KeyConfidence,
+		}).Error; err != nil {
+			return err
+		}
+		if result.BPM > 0 {
+			if err := tx.Model(&models.Sample{}).
+				Where("id = ? AND COALESCE(bpm_source, '') <> ?", sample.ID, BPMSourceUser).
+				Updates(map[string]interface{}{"bpm": result.BPM, "bpm_source": BPMSourceAnalysis}).Error; err != nil {
+				return err
+			}
+		}
+		if result.Key != "" {
+			if err := tx.Model(&models.Sample{}).
+				Where("id = ? AND COALESCE(key_source, '') <> ?", sample.ID, KeySourceUser).
+				Updates(map[string]interface{}{"key": result.Key, "key_source": KeySourceAnalysis}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OverrideAnalysis lets the uploader of a sample replace its BPM and key.
+// A zero BPM or empty key reverts to the analysis estimate.
+func (s *Service) OverrideAnalysis(packID, sampleID, userID uint, bpm *float64, key *string) (*models.Sample, error) {
+	var sample models.Sample
+	if err := db.GetDB().Where("sample_pack_id = ?", packID).First(&sample, sampleID).Error; err != nil {
+		return nil, errors.NewNotFoundError("Sample")
+	}
+	if sample.UserID != userID {
+		return nil, errors.NewAuthorizationError("Only the uploader can edit this sample")
+	}
+
+	updates := map[string]interface{}{}
+	if bpm != nil {
+		switch {
+		case *bpm == 0:
+			updates["bpm"] = sample.EstimatedBPM
+			updates["bpm_source"] = sourceFor(sample.EstimatedBPM > 0, BPMSourceAnalysis)
+		case *bpm < MinBPM || *bpm > MaxBPM:
+			return nil, errors.NewValidationError("bpm", "BPM must be a number between 20 and 400")
+		default:
+			updates["bpm"] = *bpm
+			updates["bpm_source"] = BPMSourceUser
+		}
+	}
+	if key != nil {
+		if *key == "" {
+			updates["key"] = sample.EstimatedKey
+			updates["key_source"] = sourceFor(sample.EstimatedKey != "", KeySourceAnalysis)
+		} else {
+			normalized, err := audio.ParseKey(*key)
+			if err != nil {
+				return nil, errors.NewValidationError("key", "Key must be a note name with an optional mode, e.g. \"F#m\" or \"Eb major\"")
+			}
+			updates["key"] = normalized
+			updates["key_source"] = KeySourceUser
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := db.GetDB().Model(&sample).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &sample, nil
+}
+
+func sourceFor(ok bool, source string) string {
+	if ok {
+		return source
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+package samplepack
+
+import (
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+)
+
+// SetAnonymity turns blind listening for a pack on or off
+func (s *Service) SetAnonymity(id uint, anonymity models.Anonymity) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pack.Anonymity = anonymity
+	if err := db.GetDB().Model(pack).Select("anonymous_submissions", "reveal_at").Updates(pack).Error; err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
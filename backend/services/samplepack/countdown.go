@@ -0,0 +1,93 @@
+package samplepack
+
+import (
+	"log"
+	"time"
+
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/models"
+)
+
+// Pack phases reported in countdowns
+const (
+	PhaseUpcoming    = "upcoming"    // upload window not open yet
+	PhaseUploads     = "uploads"     // samples are being uploaded
+	PhaseWaiting     = "waiting"     // uploads closed, pack not open yet
+	PhaseSubmissions = "submissions" // pack open for submissions
+	PhaseClosed      = "closed"
+)
+
+// countdownFinalStretch is how long before a deadline countdown ticks are
+// published every second instead of every minute
+const countdownFinalStretch = time.Hour
+
+// Countdown is the time left until a pack's next window change
+type Countdown struct {
+	PackID    uint       `json:"packId"`
+	Title     string     `json:"title"`
+	Phase     string     `json:"phase"`
+	Deadline  *time.Time `json:"deadline,omitempty"` // end of the phase, nil once closed
+	Remaining int64      `json:"remaining"`          // whole seconds until Deadline
+}
+
+// CountdownFor returns the countdown of pack at now
+func CountdownFor(pack *models.SamplePack, now time.Time) Countdown {
+	countdown := Countdown{PackID: pack.ID, Title: pack.Title}
+
+	var deadline time.Time
+	switch {
+	case now.Before(pack.UploadStart):
+		countdown.Phase, deadline = PhaseUpcoming, pack.UploadStart
+	case now.Before(pack.UploadEnd):
+		countdown.Phase, deadline = PhaseUploads, pack.UploadEnd
+	case now.Before(pack.StartDate):
+		countdown.Phase, deadline = PhaseWaiting, pack.StartDate
+	case now.Before(pack.EndDate) && pack.IsActive:
+		countdown.Phase, deadline = PhaseSubmissions, pack.EndDate
+	default:
+		countdown.Phase = PhaseClosed
+		return countdown
+	}
+
+	countdown.Deadline = &deadline
+	countdown.Remaining = int64(deadline.Sub(now) / time.Second)
+	return countdown
+}
+
+// CurrentCountdown returns the countdown of the current pack, or nil when
+// there is none
+func (s *Service) CurrentCountdown() (*Countdown, error) {
+	pack, err := s.GetCurrentPack()
+	if err != nil || pack == nil {
+		return nil, err
+	}
+	countdown := CountdownFor(pack, time.Now())
+	return &countdown, nil
+}
+
+// RunCountdown publishes countdown ticks for the current pack, every second
+// during the final hour before a deadline and every minute otherwise
+func (s *Service) RunCountdown() {
+	for {
+		next := time.Minute
+
+		countdown, err := s.CurrentCountdown()
+		if err != nil {
+			log.Printf("Failed to get pack countdown: %v", err)
+		} else if countdown != nil {
+			s.events.Publish(events.PackCountdown, countdown)
+
+			if countdown.Deadline != nil {
+				left := time.Until(*countdown.Deadline)
+				switch {
+				case left <= countdownFinalStretch:
+					next = time.Second
+				case left-countdownFinalStretch < next:
+					next = left - countdownFinalStretch
+				}
+			}
+		}
+
+		time.Sleep(next)
+	}
+}
@@ -0,0 +1,163 @@
+package samplepack
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// GetPackVariant returns the cached archive of a pack converted to format.
+// Conversion is slow, so when there is none yet a build is queued and nil
+// is returned until it is ready.
+func (s *Service) GetPackVariant(id uint, format audio.ExportFormat) (*models.PackVariant, error) {
+	if err := db.GetDB().Select("id").First(&models.SamplePack{}, id).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NewNotFoundError("Sample pack")
+		}
+		return nil, err
+	}
+
+	variant, err := findPackVariant(id, format)
+	if err != nil {
+		return nil, err
+	}
+	if variant != nil {
+		exists, err := s.storage.Exists(variant.ArchivePath)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return variant, nil
+		}
+		log.Printf("Cached %s archive for pack %d missing at %s, rebuilding", format, id, variant.ArchivePath)
+	}
+
+	if err := s.QueueVariantBuild(id, format); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func findPackVariant(id uint, format audio.ExportFormat) (*models.PackVariant, error) {
+	var variant models.PackVariant
+	err := db.GetDB().Where("sample_pack_id = ? AND format = ?", id, format.String()).First(&variant).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// BuildPackVariant converts every sample of a pack to format and stores the
+// resulting archive alongside the original one. The build is abandoned if
+// ctx is done first.
+func (s *Service) BuildPackVariant(ctx context.Context, id uint, format audio.ExportFormat) error {
+	lock := s.archiveLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if variant, err := findPackVariant(id, format); err != nil {
+		return err
+	} else if variant != nil {
+		exists, err := s.storage.Exists(variant.ArchivePath)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+	}
+
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", fmt.Sprintf("pack_%d_%s_*", pack.ID, format))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	entries := make([]packEntry, 0, len(pack.Samples))
+	used := make(map[string]bool)
+	for _, sample := range pack.Samples {
+		name := entryName(used, strings.TrimSuffix(sample.Filename, filepath.Ext(sample.Filename))+".wav", sample.ID)
+
+		dst := filepath.Join(tmpDir, fmt.Sprintf("%d.wav", sample.ID))
+		if err := s.convertSample(ctx, sample, dst, format); err != nil {
+			return fmt.Errorf("failed to convert sample %d: %w", sample.ID, err)
+		}
+		entries = append(entries, packEntry{Sample: sample, Path: name, Source: dst, Converted: true})
+	}
+
+	archive, err := s.storeArchive(fmt.Sprintf("pack_%d_%s", pack.ID, format), func(w io.Writer) error {
+		return s.writePackZip(ctx, *pack, entries, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	variant := models.PackVariant{
+		SamplePackID: pack.ID,
+		Format:       format.String(),
+		ArchivePath:  archive.path,
+		ArchiveHash:  archive.hash,
+		ArchiveSize:  archive.size,
+	}
+	if err := db.GetDB().Where("sample_pack_id = ? AND format = ?", pack.ID, variant.Format).
+		Assign(variant).
+		FirstOrCreate(&variant).Error; err != nil {
+		s.storage.Delete(archive.path) // Clean up on error
+		return err
+	}
+
+	log.Printf("Stored %s archive for pack %d at %s (sha256 %s, %d bytes)", format, pack.ID, archive.path, archive.hash, archive.size)
+	return nil
+}
+
+// convertSample converts a stored sample into a local file at dst
+func (s *Service) convertSample(ctx context.Context, sample models.Sample, dst string, format audio.ExportFormat) error {
+	src, release, err := s.storage.LocalPath(sample.FilePath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return audio.Convert(ctx, src, dst, format)
+}
+
+// deletePackVariants removes every cached variant of a pack. The caller
+// must hold the pack's archive lock.
+func (s *Service) deletePackVariants(packID uint) error {
+	var variants []models.PackVariant
+	if err := db.GetDB().Where("sample_pack_id = ?", packID).Find(&variants).Error; err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+
+	if err := db.GetDB().Where("sample_pack_id = ?", packID).Delete(&models.PackVariant{}).Error; err != nil {
+		return err
+	}
+
+	for _, variant := range variants {
+		s.retireArchive(variant.ArchivePath)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package samplepack
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+)
+
+// ListUserSamples returns the samples a user contributed that viewer may
+// see, newest first, with how many there are in total. Samples in packs
+// that have not opened are only shown to their uploader and admins, and
+// like pack sample lists, none are shown to anonymous viewers.
+func (s *Service) ListUserSamples(userID uint, viewer Viewer, limit, offset int) ([]models.Sample, int64, error) {
+	if viewer.UserID == 0 && !viewer.IsAdmin {
+		return []models.Sample{}, 0, nil
+	}
+
+	var total int64
+	if err := s.userSamplesQuery(userID, viewer).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var samples []models.Sample
+	err := s.userSamplesQuery(userID, viewer).
+		Preload("SamplePack").
+		Order("samples.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&samples).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range samples {
+		samples[i].FileURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/download", samples[i].SamplePackID, samples[i].ID)
+		samples[i].PreviewURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/preview", samples[i].SamplePackID, samples[i].ID)
+	}
+	return samples, total, nil
+}
+
+// userSamplesQuery builds a fresh query each time, since Count and Find
+// cannot share a statement
+func (s *Service) userSamplesQuery(userID uint, viewer Viewer) *gorm.DB {
+	query := db.GetDB().Model(&models.Sample{}).Where("samples.user_id = ?", userID)
+	if viewer.UserID != userID && !viewer.IsAdmin && !s.cfg.BypassTimeWindows {
+		query = query.Joins("JOIN sample_packs ON sample_packs.id = samples.sample_pack_id").
+			Where("sample_packs.start_date <= ?", time.Now())
+	}
+	return query
+}
@@ -0,0 +1,82 @@
+package samplepack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/jobs"
+)
+
+const (
+	JobAnalyzeSample = "sample.analyze"
+	JobBuildArchive  = "pack.build_archive"
+	JobDeleteArchive = "pack.delete_archive"
+	JobBuildVariant  = "pack.build_variant"
+
+	// variantBuildTimeout bounds converting a whole pack to another format
+	variantBuildTimeout = 30 * time.Minute
+
+	// archiveGracePeriod is how long a replaced archive is kept, so that
+	// downloads already handed its path can still open it
+	archiveGracePeriod = 10 * time.Minute
+)
+
+// SampleJob is the payload of jobs operating on a single sample
+type SampleJob struct {
+	SampleID uint `json:"sampleId"`
+}
+
+// PackJob is the payload of jobs operating on a whole pack
+type PackJob struct {
+	PackID uint `json:"packId"`
+}
+
+// VariantJob is the payload of jobs building a converted pack archive
+type VariantJob struct {
+	PackID uint   `json:"packId"`
+	Format string `json:"format"` // e.g. "wav-48k-24"
+}
+
+// ArchiveFileJob is the payload of jobs operating on a stored archive file
+type ArchiveFileJob struct {
+	Path string `json:"path"`
+}
+
+// RegisterJobs registers the pack service's background jobs with q. Until it
+// is called, background work is skipped.
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.analyzeJob = jobs.Register(q, JobAnalyzeSample, 3, func(ctx context.Context, p SampleJob) error {
+		return s.AnalyzeSample(ctx, p.SampleID)
+	})
+	s.archiveJob = jobs.Register(q, JobBuildArchive, jobs.DefaultMaxAttempts, func(ctx context.Context, p PackJob) error {
+		return s.BuildPackArchive(ctx, p.PackID)
+	})
+	s.variantJob = jobs.RegisterWithTimeout(q, JobBuildVariant, 3, variantBuildTimeout, func(ctx context.Context, p VariantJob) error {
+		format, err := audio.ParseExportFormat(p.Format)
+		if err != nil {
+			return err
+		}
+		return s.BuildPackVariant(ctx, p.PackID, format)
+	})
+	s.deleteArchiveJob = jobs.Register(q, JobDeleteArchive, jobs.DefaultMaxAttempts, func(ctx context.Context, p ArchiveFileJob) error {
+		return s.DeleteArchive(p.Path)
+	})
+}
+
+// archiveJobKeyPrefix followed by the pack ID deduplicates archive builds
+const archiveJobKeyPrefix = "pack:"
+
+// QueueArchiveBuild schedules a pack archive build unless one is already pending
+func (s *Service) QueueArchiveBuild(packID uint) error {
+	_, err := s.archiveJob.EnqueueUnique(PackJob{PackID: packID}, fmt.Sprintf("%s%d", archiveJobKeyPrefix, packID))
+	return err
+}
+
+// QueueVariantBuild schedules building a pack converted to format unless
+// that build is already pending
+func (s *Service) QueueVariantBuild(packID uint, format audio.ExportFormat) error {
+	_, err := s.variantJob.EnqueueUnique(VariantJob{PackID: packID, Format: format.String()}, fmt.Sprintf("variant:%d:%s", packID, format))
+	return err
+}
@@ -0,0 +1,118 @@
+package samplepack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/storage"
+)
+
+// MeasureLoudness measures a stored file, decoding it in blocks. Files that
+// cannot be decoded come back with the failed status.
+func MeasureLoudness(ctx context.Context, store storage.Storage, filePath string) (models.Loudness, error) {
+	path, release, err := store.LocalPath(filePath)
+	if err != nil {
+		log.Printf("Failed to open %s for loudness analysis: %v", filePath, err)
+		return models.Loudness{LoudnessStatus: models.LoudnessFailed}, nil
+	}
+	defer release()
+
+	loudness, err := audio.MeasureLoudnessFile(ctx, path)
+	if ctx.Err() != nil {
+		// Interrupted, not undecodable; the job is retried
+		return models.Loudness{}, ctx.Err()
+	}
+	if err != nil {
+		log.Printf("Failed to decode %s for loudness analysis: %v", filePath, err)
+		return models.Loudness{LoudnessStatus: models.LoudnessFailed}, nil
+	}
+	return models.NewLoudness(loudness), nil
+}
+
+// LoudnessUpdates returns the columns storing loudness
+func LoudnessUpdates(loudness models.Loudness) map[string]interface{} {
+	return map[string]interface{}{
+		"loudness_status": loudness.LoudnessStatus,
+		"loudness_lufs":   loudness.LoudnessLUFS,
+		"true_peak_dbtp":  loudness.TruePeakDBTP,
+		"clipped_samples": loudness.ClippedSamples,
+	}
+}
+
+// LoudnessViolations describes every measurement that exceeds the pack's
+// loudness limits
+func LoudnessViolations(pack *models.SamplePack, loudness models.Loudness) []string {
+	limits := pack.LoudnessLimits
+	var violations []string
+
+	if limits.MaxLoudnessLUFS != nil && loudness.LoudnessLUFS != nil && *loudness.LoudnessLUFS > *limits.MaxLoudnessLUFS {
+		violations = append(violations, fmt.Sprintf("integrated loudness %.1f LUFS exceeds the pack limit of %.1f LUFS", *loudness.LoudnessLUFS, *limits.MaxLoudnessLUFS))
+	}
+	if limits.MaxTruePeakDBTP != nil && loudness.TruePeakDBTP != nil && *loudness.TruePeakDBTP > *limits.MaxTruePeakDBTP {
+		violations = append(violations, fmt.Sprintf("true peak %.1f dBTP exceeds the pack limit of %.1f dBTP", *loudness.TruePeakDBTP, *limits.MaxTruePeakDBTP))
+	}
+	if limits.MaxClippedSamples != nil && loudness.ClippedSamples != nil && *loudness.ClippedSamples > *limits.MaxClippedSamples {
+		violations = append(violations, fmt.Sprintf("%d clipped samples exceeds the pack limit of %d", *loudness.ClippedSamples, *limits.MaxClippedSamples))
+	}
+	return violations
+}
+
+// checkSampleLoudness measures a sample and removes it if it exceeds its
+// pack's limits, telling the uploader why. It reports whether the sample
+// was removed.
+func (s *Service) checkSampleLoudness(ctx context.Context, sample *models.Sample) (bool, error) {
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, sample.SamplePackID).Error; err != nil {
+		return false, err
+	}
+
+	loudness, err := MeasureLoudness(ctx, s.storage, sample.FilePath)
+	if err != nil {
+		return false, err
+	}
+	violations := LoudnessViolations(&pack, loudness)
+	if len(violations) > 0 {
+		loudness.LoudnessStatus = models.LoudnessRejected
+	}
+	if err := db.GetDB().Model(sample).Updates(LoudnessUpdates(loudness)).Error; err != nil {
+		return false, err
+	}
+	sample.Loudness = loudness
+	if len(violations) == 0 {
+		return false, nil
+	}
+
+	if err := s.RemoveSample(sample.SamplePackID, sample.ID); err != nil {
+		return false, err
+	}
+	log.Printf("Rejected sample %d: %s", sample.ID, strings.Join(violations, "; "))
+	s.events.Publish(events.UploadRejected, events.UploadRejectedData{
+		Kind:     "sample",
+		ID:       sample.ID,
+		PackID:   sample.SamplePackID,
+		UserID:   sample.UserID,
+		Filename: sample.Filename,
+		Reason:   strings.Join(violations, "; "),
+	})
+	return true, nil
+}
+
+// SetLoudnessLimits replaces the loudness limits of a pack
+func (s *Service) SetLoudnessLimits(id uint, limits models.LoudnessLimits) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pack.LoudnessLimits = limits
+	if err := db.GetDB().Model(pack).Select("max_loudness_lufs", "max_true_peak_dbtp", "max_clipped_samples").Updates(pack).Error; err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
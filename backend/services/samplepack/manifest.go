@@ -0,0 +1,200 @@
+package samplepack
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/models"
+)
+
+const (
+	ManifestFilename = "manifest.json"
+	CreditsFilename  = "CREDITS.txt"
+)
+
+// Manifest is the machine-readable description of a pack archive, shipped
+// inside the zip and served by the manifest endpoint.
+type Manifest struct {
+	PackID      uint             `json:"packId"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Samples     []ManifestSample `json:"samples"`
+}
+
+type ManifestSample struct {
+	ID               uint                `json:"id"`
+	Path             string              `json:"path"` // location inside the archive
+	OriginalFilename string              `json:"originalFilename"`
+	Contributor      ManifestContributor `json:"contributor"`
+	FileSize         int64               `json:"fileSize"`
+	SHA256           string              `json:"sha256"`
+	License          models.LicenseTerms `json:"license"`
+	Audio            *audio.Info         `json:"audio,omitempty"`
+}
+
+type ManifestContributor struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func newManifestSample(entry packEntry, sum string) ManifestSample {
+	sample := entry.Sample
+	result := ManifestSample{
+		ID:               sample.ID,
+		Path:             entry.Path,
+		OriginalFilename: sample.Filename,
+		Contributor: ManifestContributor{
+			ID:   sample.UserID,
+			Name: sample.User.Name,
+		},
+		FileSize: sample.FileSize,
+		SHA256:   sum,
+		License:  sample.License.Terms(),
+	}
+
+	if sample.Format != "" && !entry.Converted {
+		result.Audio = &audio.Info{
+			Format:     sample.Format,
+			SampleRate: sample.SampleRate,
+			BitDepth:   sample.BitDepth,
+			Channels:   sample.Channels,
+			Duration:   sample.Duration,
+		}
+	} else if info, err := audio.Probe(entry.Source); err == nil {
+		result.Audio = info
+	} else {
+		log.Printf("Failed to probe sample %d for manifest: %v", sample.ID, err)
+	}
+
+	if entry.Converted {
+		if stat, err := os.Stat(entry.Source); err == nil {
+			result.FileSize = stat.Size()
+		}
+	}
+
+	return result
+}
+
+func writeManifest(zipWriter *zip.Writer, manifest *Manifest) error {
+	w, err := zipWriter.Create(ManifestFilename)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	w, err = zipWriter.Create(CreditsFilename)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, formatCredits(manifest))
+	return err
+}
+
+// formatCredits renders the human-readable CREDITS.txt for a manifest
+func formatCredits(manifest *Manifest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", manifest.Title)
+	if manifest.Description != "" {
+		fmt.Fprintf(&b, "%s\n", manifest.Description)
+	}
+	fmt.Fprintf(&b, "\nSamples (%d)\n\n", len(manifest.Samples))
+
+	for _, sample := range manifest.Samples {
+		fmt.Fprintf(&b, "%s\n", sample.Path)
+		fmt.Fprintf(&b, "  Contributor: %s\n", sample.Contributor.Name)
+		if sample.OriginalFilename != sample.Path {
+			fmt.Fprintf(&b, "  Original filename: %s\n", sample.OriginalFilename)
+		}
+		if sample.Audio != nil {
+			fmt.Fprintf(&b, "  Audio: %s\n", formatAudioInfo(sample.Audio))
+		}
+		if sample.License.URL != "" {
+			fmt.Fprintf(&b, "  License: %s (%s)\n", sample.License.Name, sample.License.URL)
+		} else {
+			fmt.Fprintf(&b, "  License: %s\n", sample.License.Name)
+		}
+		fmt.Fprintf(&b, "  SHA-256: %s\n\n", sample.SHA256)
+	}
+
+	return b.String()
+}
+
+func formatAudioInfo(info *audio.Info) string {
+	parts := []string{strings.ToUpper(info.Format), fmt.Sprintf("%d Hz", info.SampleRate)}
+	if info.BitDepth > 0 {
+		parts = append(parts, fmt.Sprintf("%d-bit", info.BitDepth))
+	}
+	switch info.Channels {
+	case 1:
+		parts = append(parts, "mono")
+	case 2:
+		parts = append(parts, "stereo")
+	default:
+		parts = append(parts, fmt.Sprintf("%d channels", info.Channels))
+	}
+	parts = append(parts, fmt.Sprintf("%.2fs", info.Duration))
+	return strings.Join(parts, ", ")
+}
+
+// GetPackManifest returns the manifest shipped in the pack's cached archive
+func (s *Service) GetPackManifest(ctx context.Context, id uint) (*Manifest, error) {
+	pack, err := s.GetPackArchive(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.storage.Open(pack.ArchivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	archive, err := zip.NewReader(readerAt{file}, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	f, err := archive.Open(ManifestFilename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// readerAt lets archive/zip read from a stored file, which may be decrypted
+// on the fly and so only supports seeking. It is not safe for concurrent use.
+type readerAt struct {
+	io.ReadSeeker
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,178 @@
+package samplepack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sample-exchange/backend/audio"
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+)
+
+const (
+	MaxTags           = 10
+	MaxTagLength      = 32
+	MaxDescriptionLen = 500
+	MinBPM            = 20
+	MaxBPM            = 400
+)
+
+// Categories lists the instrument categories a sample can be filed under
+var Categories = []string{
+	"drums", "percussion", "bass", "synth", "keys", "guitar",
+	"strings", "brass", "vocals", "fx", "ambient", "foley", "other",
+}
+
+// SampleMetadata is the descriptive information supplied with an upload
+type SampleMetadata struct {
+	Description string
+	Category    string
+	Tags        []string
+	BPM         string
+	Key         string
+}
+
+// ApplySampleMetadata validates and normalizes metadata and stores it on sample
+func ApplySampleMetadata(sample *models.Sample, meta SampleMetadata) error {
+	description := strings.TrimSpace(meta.Description)
+	if len(description) > MaxDescriptionLen {
+		return errors.NewValidationError("description", "Description must be at most 500 characters")
+	}
+
+	category := strings.ToLower(strings.TrimSpace(meta.Category))
+	if category != "" && !isCategory(category) {
+		return errors.NewValidationError("category", "Unknown category. Allowed categories: "+strings.Join(Categories, ", "))
+	}
+
+	tags, err := normalizeTags(meta.Tags)
+	if err != nil {
+		return err
+	}
+
+	var bpm float64
+	if value := strings.TrimSpace(meta.BPM); value != "" {
+		bpm, err = strconv.ParseFloat(value, 64)
+		if err != nil || bpm < MinBPM || bpm > MaxBPM {
+			return errors.NewValidationError("bpm", "BPM must be a number between 20 and 400")
+		}
+	}
+
+	var key string
+	if value := strings.TrimSpace(meta.Key); value != "" {
+		key, err = audio.ParseKey(value)
+		if err != nil {
+			return errors.NewValidationError("key", "Key must be a note name with an optional mode, e.g. \"F#m\" or \"Eb major\"")
+		}
+	}
+
+	sample.Description = description
+	sample.Category = category
+	sample.Tags = tags
+	sample.BPM = bpm
+	sample.Key = key
+	if bpm > 0 {
+		sample.BPMSource = BPMSourceUser
+	}
+	if key != "" {
+		sample.KeySource = KeySourceUser
+	}
+	return nil
+}
+
+func isCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags lowercases tags, replaces whitespace with dashes and drops
+// duplicates. Tags may also be given comma-separated.
+func normalizeTags(raw []string) (models.Tags, error) {
+	tags := models.Tags{}
+	seen := make(map[string]bool)
+	for _, value := range raw {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if len(tag) > MaxTagLength {
+				return nil, errors.NewValidationError("tags", "Tags must be at most 32 characters")
+			}
+			for _, r := range tag {
+				if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+					return nil, errors.NewValidationError("tags", "Tags may only contain letters, digits and dashes")
+				}
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTags {
+		return nil, errors.NewValidationError("tags", "At most 10 tags are allowed")
+	}
+	return tags, nil
+}
+
+// SampleFilter narrows the samples returned by ListSamples. Zero values are
+// ignored.
+type SampleFilter struct {
+	Tags     []string
+	Category string
+	Key      string
+	MinBPM   float64
+	MaxBPM   float64
+	Query    string // matched against filename and description
+	UserID   uint   // only samples uploaded by this user
+}
+
+// ListSamples returns the samples in a pack matching filter
+func (s *Service) ListSamples(packID uint, filter SampleFilter) ([]models.Sample, error) {
+	query := db.GetDB().Where("sample_pack_id = ?", packID)
+
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		query = query.Where("',' || tags || ',' LIKE ?", "%,"+tag+",%")
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", strings.ToLower(filter.Category))
+	}
+	if filter.Key != "" {
+		key, err := audio.ParseKey(filter.Key)
+		if err != nil {
+			return nil, errors.NewValidationError("key", "Invalid key")
+		}
+		query = query.Where("key = ?", key)
+	}
+	if filter.MinBPM > 0 {
+		query = query.Where("bpm >= ?", filter.MinBPM)
+	}
+	if filter.MaxBPM > 0 {
+		query = query.Where("bpm <= ?", filter.MaxBPM)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(filename) LIKE ? OR LOWER(description) LIKE ?", like, like)
+	}
+
+	var samples []models.Sample
+	if err := query.Preload("User").Order("created_at").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range samples {
+		samples[i].PreviewURL = fmt.Sprintf("/api/samples/packs/%d/samples/%d/preview", packID, samples[i].ID)
+	}
+	return samples, nil
+}
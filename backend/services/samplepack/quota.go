@@ -0,0 +1,20 @@
+package samplepack
+
+import (
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+)
+
+// SetUploadQuota sets the per-user sample quota of a pack
+func (s *Service) SetUploadQuota(id uint, quota models.Quota) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pack.UploadQuota = quota
+	if err := db.GetDB().Model(pack).Select("upload_quota_max_files", "upload_quota_max_bytes").Updates(pack).Error; err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
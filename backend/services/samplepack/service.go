@@ -2,28 +2,58 @@ package samplepack
 
 import (
 	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	stderrors "errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"sample-exchange/backend/config"
 	"sample-exchange/backend/db"
 	"sample-exchange/backend/errors"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
 	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/quota"
+	"sample-exchange/backend/storage"
 
 	"gorm.io/gorm"
 )
 
 type Service struct {
-	cfg *config.Config
+	cfg     *config.Config
+	storage storage.Storage
+
+	// archiveLocks serializes archive builds and invalidation per pack
+	archiveMu    sync.Mutex
+	archiveLocks map[uint]*sync.Mutex
+
+	analyzeJob       *jobs.JobType[SampleJob]
+	archiveJob       *jobs.JobType[PackJob]
+	variantJob       *jobs.JobType[VariantJob]
+	deleteArchiveJob *jobs.JobType[ArchiveFileJob]
+
+	events *events.Bus
+	quota  *quota.Service
+}
+
+// SetQuotaService makes the service enforce upload quotas when adding samples
+func (s *Service) SetQuotaService(q *quota.Service) {
+	s.quota = q
 }
 
-func NewService(cfg *config.Config) *Service {
+func NewService(cfg *config.Config, store storage.Storage) *Service {
 	return &Service{
-		cfg: cfg,
+		cfg:          cfg,
+		storage:      store,
+		archiveLocks: make(map[uint]*sync.Mutex),
 	}
 }
 
@@ -51,6 +81,18 @@ func (s *Service) GetPack(id uint) (*models.SamplePack, error) {
 	return &pack, err
 }
 
+// GetSample returns a sample belonging to a pack
+func (s *Service) GetSample(packID, sampleID uint) (*models.Sample, error) {
+	var sample models.Sample
+	err := db.GetDB().Where("sample_pack_id = ?", packID).
+		Preload("User").
+		First(&sample, sampleID).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("Sample")
+	}
+	return &sample, err
+}
+
 func (s *Service) ListPacks(limit int) ([]models.SamplePack, error) {
 	var packs []models.SamplePack
 	result := db.GetDB().Where("is_active = ?", false).Order("created_at desc").Limit(limit).Find(&packs)
@@ -139,10 +181,85 @@ func (s *Service) AddSample(packID uint, sample *models.Sample) error {
 		return errors.NewAuthorizationError("Upload window is closed")
 	}
 
-	return db.GetDB().Model(pack).Association("Samples").Append(sample)
+	// Loudness is measured and checked against the pack's limits by the
+	// analysis job
+	sample.LoudnessStatus = models.LoudnessPending
+	if analyzableFormats[sample.Format] {
+		sample.AnalysisStatus = AnalysisPending
+	} else {
+		sample.AnalysisStatus = AnalysisSkipped
+	}
+
+	// The quota is checked in the same transaction, so concurrent uploads
+	// cannot both fit under it
+	err = db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if s.quota != nil {
+			if err := s.quota.CheckSampleTx(tx, sample.UserID, packID, sample.FileSize); err != nil {
+				return err
+			}
+		}
+		return tx.Model(pack).Association("Samples").Append(sample)
+	})
+	if err != nil {
+		return err
+	}
+	s.events.Publish(events.SampleUploaded, events.NewSampleData(sample))
+
+	if _, err := s.analyzeJob.Enqueue(SampleJob{SampleID: sample.ID}); err != nil {
+		log.Printf("Failed to queue analysis for sample %d: %v", sample.ID, err)
+	}
+	if err := s.refreshNonCommercial(packID); err != nil {
+		return err
+	}
+
+	return s.InvalidatePackArchive(packID)
+}
+
+// GetSampleCredits returns license and attribution information for every
+// sample in a pack
+func (s *Service) GetSampleCredits(packID uint) ([]models.SampleCredit, error) {
+	var samples []models.Sample
+	if err := db.GetDB().Where("sample_pack_id = ?", packID).
+		Preload("User").
+		Order("id").
+		Find(&samples).Error; err != nil {
+		return nil, err
+	}
+
+	credits := make([]models.SampleCredit, 0, len(samples))
+	for _, sample := range samples {
+		credits = append(credits, models.SampleCredit{
+			SampleID:    sample.ID,
+			Filename:    sample.Filename,
+			Contributor: sample.User.Name,
+			Terms:       sample.License.Terms(),
+		})
+	}
+	return credits, nil
+}
+
+// HasNonCommercialSamples reports whether any sample in the pack forbids
+// commercial use of derived works
+func (s *Service) HasNonCommercialSamples(packID uint) (bool, error) {
+	var count int64
+	err := db.GetDB().Model(&models.Sample{}).
+		Where("sample_pack_id = ? AND license IN ?", packID, models.NonCommercialLicenses()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// refreshNonCommercial updates the non-commercial flag of the pack's
+// submissions after its samples change
+func (s *Service) refreshNonCommercial(packID uint) error {
+	nonCommercial, err := s.HasNonCommercialSamples(packID)
+	if err != nil {
+		return err
+	}
+	return db.GetDB().Model(&models.Submission{}).
+		Where("sample_pack_id = ? AND non_commercial <> ?", packID, nonCommercial).
+		Update("non_commercial", nonCommercial).Error
 }
 
-// CreatePackZip creates a zip file containing all samples in a pack
 // CreateTestPack creates a sample pack with test data
 func (s *Service) CreateTestPack(userID uint) (*models.SamplePack, error) {
 	pack, err := s.CreatePack()
@@ -180,53 +297,352 @@ func (s *Service) CreateTestPack(userID uint) (*models.SamplePack, error) {
 	return pack, nil
 }
 
-func (s *Service) CreatePackZip(pack models.SamplePack, zipPath string) error {
-	log.Printf("Creating zip file for pack %d with %d samples", pack.ID, len(pack.Samples))
+// CreatePackZip writes a zip archive containing all samples in a pack to w,
+// followed by its manifest and credits. It stops early if ctx is done.
+func (s *Service) CreatePackZip(ctx context.Context, pack models.SamplePack, w io.Writer) error {
+	entries := make([]packEntry, 0, len(pack.Samples))
+	used := make(map[string]bool)
+	for _, sample := range pack.Samples {
+		entries = append(entries, packEntry{Sample: sample, Path: entryName(used, sample.Filename, sample.ID), Source: sample.FilePath})
+	}
+	return s.writePackZip(ctx, pack, entries, w)
+}
 
-	// Create the zip file
-	zipFile, err := os.Create(zipPath)
-	if err != nil {
-		log.Printf("Failed to create zip file at %s: %v", zipPath, err)
-		return fmt.Errorf("failed to create zip file: %w", err)
+// entryName returns name, with the sample ID appended if another entry in
+// the archive already uses it, and records it in used
+func entryName(used map[string]bool, name string, sampleID uint) string {
+	for used[name] {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), sampleID, ext)
 	}
-	defer zipFile.Close()
+	used[name] = true
+	return name
+}
+
+// packEntry is a file to add to a pack archive
+type packEntry struct {
+	Sample    models.Sample
+	Path      string // name inside the archive
+	Source    string // file to copy
+	Converted bool   // Source is a converted copy of the sample
+}
+
+func (s *Service) writePackZip(ctx context.Context, pack models.SamplePack, entries []packEntry, w io.Writer) error {
+	log.Printf("Creating zip file for pack %d with %d samples", pack.ID, len(entries))
 
 	// Create a new zip writer
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	zipWriter := zip.NewWriter(w)
+
+	manifest := &Manifest{
+		PackID:      pack.ID,
+		Title:       pack.Title,
+		Description: pack.Description,
+		Samples:     make([]ManifestSample, 0, len(entries)),
+	}
 
 	// Add each sample to the zip
-	for _, sample := range pack.Samples {
-		log.Printf("Adding sample %d (%s) from %s", sample.ID, sample.Filename, sample.FilePath)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Printf("Adding sample %d (%s) from %s", entry.Sample.ID, entry.Path, entry.Source)
 
-		// Open the sample file using the stored file path
-		sampleFile, err := os.Open(sample.FilePath)
+		sum, err := s.addFileToZip(zipWriter, entry)
 		if err != nil {
-			log.Printf("Failed to open sample file %d at %s: %v", sample.ID, sample.FilePath, err)
-			return fmt.Errorf("failed to open sample file %d: %w", sample.ID, err)
+			log.Printf("Failed to add sample %d to zip: %v", entry.Sample.ID, err)
+			return fmt.Errorf("failed to add sample %d to zip: %w", entry.Sample.ID, err)
 		}
-		defer sampleFile.Close()
+		if !entry.Converted && entry.Sample.SHA256 != "" && sum != entry.Sample.SHA256 {
+			log.Printf("Sample %d failed integrity check: expected %s, got %s", entry.Sample.ID, entry.Sample.SHA256, sum)
+			return fmt.Errorf("sample %d: %w", entry.Sample.ID, storage.ErrChecksumMismatch)
+		}
+		manifest.Samples = append(manifest.Samples, newManifestSample(entry, sum))
+
+		log.Printf("Successfully added sample %d to zip", entry.Sample.ID)
+	}
+
+	if err := writeManifest(zipWriter, manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
 
-		// Create a new file in the zip
-		zipEntry, err := zipWriter.Create(sample.Filename)
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zip file: %w", err)
+	}
+
+	log.Printf("Successfully created zip file for pack %d", pack.ID)
+	return nil
+}
+
+// addFileToZip copies the source of entry into the zip and returns its
+// SHA-256. Stored samples are read through storage, converted copies from
+// their temporary file.
+func (s *Service) addFileToZip(zipWriter *zip.Writer, entry packEntry) (string, error) {
+	var file io.ReadCloser
+	var err error
+	if entry.Converted {
+		file, err = os.Open(entry.Source)
+	} else {
+		file, err = s.storage.Open(entry.Source)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// Create a new file in the zip
+	zipEntry, err := zipWriter.Create(entry.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// Copy the sample file into the zip
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(zipEntry, hash), file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetPackArchive returns a pack whose cached archive is up to date, building
+// the archive first if this is the first request for it.
+func (s *Service) GetPackArchive(ctx context.Context, id uint) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+	if pack.ArchiveHash != "" {
+		exists, err := s.storage.Exists(pack.ArchivePath)
 		if err != nil {
-			log.Printf("Failed to create zip entry for sample %d: %v", sample.ID, err)
-			return fmt.Errorf("failed to create zip entry for sample %d: %w", sample.ID, err)
+			return nil, err
 		}
+		if exists {
+			return pack, nil
+		}
+		log.Printf("Cached archive for pack %d missing at %s, rebuilding", pack.ID, pack.ArchivePath)
+	}
 
-		// Copy the sample file into the zip
-		if _, err := io.Copy(zipEntry, sampleFile); err != nil {
-			log.Printf("Failed to copy sample %d to zip: %v", sample.ID, err)
-			return fmt.Errorf("failed to copy sample %d to zip: %w", sample.ID, err)
+	if err := s.BuildPackArchive(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.GetPack(id)
+}
+
+// BuildPackArchive builds the pack zip once, stores it through storage under
+// its content hash and records the result on the pack. The build is
+// abandoned if ctx is done first.
+func (s *Service) BuildPackArchive(ctx context.Context, id uint) error {
+	// Serialize builds so a burst of first downloads only builds the zip once
+	lock := s.archiveLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return err
+	}
+	if pack.ArchiveHash != "" {
+		exists, err := s.storage.Exists(pack.ArchivePath)
+		if err != nil {
+			return err
 		}
+		if exists {
+			return nil
+		}
+	}
+
+	archive, err := s.storeArchive(fmt.Sprintf("pack_%d", pack.ID), func(w io.Writer) error {
+		return s.CreatePackZip(ctx, *pack, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := db.GetDB().Model(pack).Updates(map[string]interface{}{
+		"archive_path": archive.path,
+		"archive_hash": archive.hash,
+		"archive_size": archive.size,
+	}).Error; err != nil {
+		s.storage.Delete(archive.path) // Clean up on error
+		return err
+	}
+
+	log.Printf("Stored archive for pack %d at %s (sha256 %s, %d bytes)", pack.ID, archive.path, archive.hash, archive.size)
+	return nil
+}
+
+type storedArchive struct {
+	path string
+	hash string
+	size int64
+}
+
+// storeArchive writes a zip through write into a temporary file, then saves
+// it through storage under prefix and its content hash
+func (s *Service) storeArchive(prefix string, write func(io.Writer) error) (*storedArchive, error) {
+	tmp, err := os.CreateTemp("", prefix+"_*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary zip file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := write(io.MultiWriter(tmp, hash, counter)); err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind zip file: %w", err)
+	}
+
+	// The build time keeps a rebuild with the same contents from overwriting
+	// an archive that may still be downloading
+	sum := hex.EncodeToString(hash.Sum(nil))
+	stored, err := s.storage.SaveArchive(tmp, fmt.Sprintf("%s_%s_%d.zip", prefix, sum[:16], time.Now().UnixNano()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store zip file: %w", err)
+	}
+	return &storedArchive{path: stored.Path, hash: sum, size: counter.n}, nil
+}
+
+// InvalidatePackArchive drops the cached archive so the next download rebuilds it
+func (s *Service) InvalidatePackArchive(id uint) error {
+	lock := s.archiveLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, id).Error; err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.NewNotFoundError("Sample pack")
+		}
+		return err
+	}
+	if err := s.deletePackVariants(pack.ID); err != nil {
+		return err
+	}
+	if pack.ArchivePath == "" {
+		return nil
+	}
+
+	if err := db.GetDB().Model(&pack).Updates(map[string]interface{}{
+		"archive_path": "",
+		"archive_hash": "",
+		"archive_size": 0,
+	}).Error; err != nil {
+		return err
+	}
+
+	s.retireArchive(pack.ArchivePath)
+	return nil
+}
+
+// archiveLock returns the lock guarding the archives of a pack
+func (s *Service) archiveLock(packID uint) *sync.Mutex {
+	s.archiveMu.Lock()
+	defer s.archiveMu.Unlock()
+
+	lock, ok := s.archiveLocks[packID]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.archiveLocks[packID] = lock
+	}
+	return lock
+}
 
-		log.Printf("Successfully added sample %d to zip", sample.ID)
+// retireArchive deletes an archive that is no longer current once
+// downloads that were handed its path have had time to open it
+func (s *Service) retireArchive(path string) {
+	if _, err := s.deleteArchiveJob.EnqueueAt(ArchiveFileJob{Path: path}, "", time.Now().Add(archiveGracePeriod)); err != nil {
+		// The storage check removes it as an orphan instead
+		log.Printf("Failed to queue deletion of stale archive %s: %v", path, err)
+	}
+}
+
+// DeleteArchive deletes a stale archive file unless a pack or variant has
+// been pointed back at it since
+func (s *Service) DeleteArchive(path string) error {
+	var refs int64
+	if err := db.GetDB().Unscoped().Model(&models.SamplePack{}).Where("archive_path = ?", path).Count(&refs).Error; err != nil {
+		return err
+	}
+	if refs == 0 {
+		if err := db.GetDB().Model(&models.PackVariant{}).Where("archive_path = ?", path).Count(&refs).Error; err != nil {
+			return err
+		}
+	}
+	if refs > 0 {
+		return nil
 	}
 
-	log.Printf("Successfully created zip file at %s", zipPath)
+	if err := s.storage.Delete(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
+// QueueClosedPackArchives queues archive builds for every pack whose upload
+// window has closed but which has no cached archive yet. Packs whose build
+// has failed permanently since they last changed are left for an admin to
+// retry the job, rather than failing again every interval.
+func (s *Service) QueueClosedPackArchives() error {
+	var ids []uint
+	if err := db.GetDB().Model(&models.SamplePack{}).
+		Where("upload_end < ? AND (archive_hash = '' OR archive_hash IS NULL)", time.Now()).
+		Where(`NOT EXISTS (SELECT 1 FROM jobs WHERE jobs.type = ? AND jobs.key = CONCAT(?::text, sample_packs.id)
+			AND jobs.status = ? AND jobs.completed_at > sample_packs.updated_at)`,
+			JobBuildArchive, archiveJobKeyPrefix, models.JobDead).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.QueueArchiveBuild(id); err != nil {
+			log.Printf("Failed to queue archive build for pack %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// RunArchiveBuilder periodically queues archive builds for packs whose upload window
+// has closed, so they are ready before the submission window opens.
+func (s *Service) RunArchiveBuilder(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.QueueClosedPackArchives(); err != nil {
+			log.Printf("Failed to queue closed pack archives: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// RemoveSample removes a sample from a pack and invalidates its cached archive
+func (s *Service) RemoveSample(packID, sampleID uint) error {
+	result := db.GetDB().Where("sample_pack_id = ?", packID).Delete(&models.Sample{}, sampleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("Sample")
+	}
+	if err := s.refreshNonCommercial(packID); err != nil {
+		return err
+	}
+
+	return s.InvalidatePackArchive(packID)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
 func (s *Service) IsUploadAllowedForPack(packID uint) bool {
 	if s.cfg.BypassTimeWindows {
 		return true
@@ -0,0 +1,114 @@
+package samplepack
+
+import (
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+)
+
+// Viewer identifies who is looking at a pack. The zero value is an
+// anonymous visitor.
+type Viewer struct {
+	UserID  uint
+	IsAdmin bool
+}
+
+// IsPackOpen reports whether a pack's submission window has started, which
+// is when its samples are released to everyone
+func (s *Service) IsPackOpen(pack *models.SamplePack) bool {
+	return s.cfg.BypassTimeWindows || !time.Now().Before(pack.StartDate)
+}
+
+// CanSeeAllSamples reports whether viewer may list and download every
+// sample in pack. Until the pack opens only admins can; afterwards any
+// authenticated user can.
+func (s *Service) CanSeeAllSamples(pack *models.SamplePack, viewer Viewer) bool {
+	if viewer.IsAdmin {
+		return true
+	}
+	return viewer.UserID != 0 && s.IsPackOpen(pack)
+}
+
+// CanAccessSample reports whether viewer may download or preview sample.
+// Uploaders can always reach their own samples.
+func (s *Service) CanAccessSample(pack *models.SamplePack, sample *models.Sample, viewer Viewer) bool {
+	return (viewer.UserID != 0 && sample.UserID == viewer.UserID) || s.CanSeeAllSamples(pack, viewer)
+}
+
+// GetPackFor returns a pack as viewer may see it. Samples viewer cannot
+// access are left out, but the sample and contributor counts always cover
+// the whole pack.
+func (s *Service) GetPackFor(id uint, viewer Viewer) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	contributors := make(map[uint]bool)
+	for _, sample := range pack.Samples {
+		contributors[sample.UserID] = true
+	}
+	pack.SampleCount = len(pack.Samples)
+	pack.ContributorCount = len(contributors)
+	pack.IsOpen = s.IsPackOpen(pack)
+
+	if !s.CanSeeAllSamples(pack, viewer) {
+		visible := make([]models.Sample, 0)
+		for _, sample := range pack.Samples {
+			if viewer.UserID != 0 && sample.UserID == viewer.UserID {
+				visible = append(visible, sample)
+			}
+		}
+		pack.Samples = visible
+	}
+	return pack, nil
+}
+
+// CheckPackDownload returns an authorization error unless viewer may
+// download the complete pack
+func (s *Service) CheckPackDownload(id uint, viewer Viewer) error {
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, id).Error; err != nil {
+		return errors.NewNotFoundError("Sample pack")
+	}
+	if !s.CanSeeAllSamples(&pack, viewer) {
+		return errors.NewAuthorizationError("Pack is not available until it opens")
+	}
+	return nil
+}
+
+// ListSamplesFor returns the samples in a pack matching filter that viewer
+// may access
+func (s *Service) ListSamplesFor(packID uint, filter SampleFilter, viewer Viewer) ([]models.Sample, error) {
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, packID).Error; err != nil {
+		return nil, errors.NewNotFoundError("Sample pack")
+	}
+
+	if !s.CanSeeAllSamples(&pack, viewer) {
+		if viewer.UserID == 0 {
+			return []models.Sample{}, nil
+		}
+		filter.UserID = viewer.UserID
+	}
+	return s.ListSamples(packID, filter)
+}
+
+// GetSampleFor returns a sample belonging to a pack if viewer may access it
+func (s *Service) GetSampleFor(packID, sampleID uint, viewer Viewer) (*models.Sample, error) {
+	sample, err := s.GetSample(packID, sampleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pack models.SamplePack
+	if err := db.GetDB().First(&pack, packID).Error; err != nil {
+		return nil, err
+	}
+	if !s.CanAccessSample(&pack, sample, viewer) {
+		return nil, errors.NewAuthorizationError("Sample is not available until the pack opens")
+	}
+	return sample, nil
+}
@@ -0,0 +1,135 @@
+package samplepack
+
+import (
+	"log"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/models"
+)
+
+// windowEventGrace is how late a window change may be noticed and still be
+// announced. Older changes, such as those of packs created before the
+// watcher existed, are recorded silently.
+const windowEventGrace = 24 * time.Hour
+
+// UploadReminderLead is how long before the upload window closes that
+// PackUploadsClosing is published
+const UploadReminderLead = 24 * time.Hour
+
+// windowTransition is a pack window change the watcher publishes
+type windowTransition struct {
+	event  string
+	column string        // records that the event was published
+	at     string        // when the change happens
+	lead   time.Duration // how long before at the event is published
+}
+
+var windowTransitions = []windowTransition{
+	{events.PackUploadsClosing, "uploads_closing_at", "upload_end", UploadReminderLead},
+	{events.PackUploadsClosed, "uploads_closed_at", "upload_end", 0},
+	{events.PackOpened, "opened_at", "start_date", 0},
+	{events.PackClosed, "closed_at", "end_date", 0},
+}
+
+// SetEventBus makes the service publish pack and sample events to bus
+func (s *Service) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
+// PublishPackCreated announces a pack once its details have been saved
+func (s *Service) PublishPackCreated(pack *models.SamplePack) {
+	s.events.Publish(events.PackCreated, events.NewPackData(pack))
+}
+
+// PublishWindowChanges publishes an event for every pack whose upload
+// window is about to close or closed, or whose submission window opened or
+// closed since it was last called
+func (s *Service) PublishWindowChanges() error {
+	now := time.Now()
+	for _, t := range windowTransitions {
+		var packs []models.SamplePack
+		if err := db.GetDB().Where(t.at+" <= ? AND "+t.column+" IS NULL", now.Add(t.lead)).Find(&packs).Error; err != nil {
+			return err
+		}
+		for i := range packs {
+			s.markWindowChange(&packs[i], t, now)
+		}
+	}
+	return nil
+}
+
+// markWindowChange records a window change once, publishing it unless it
+// happened too long ago
+func (s *Service) markWindowChange(pack *models.SamplePack, t windowTransition, now time.Time) {
+	result := db.GetDB().Model(&models.SamplePack{}).
+		Where("id = ? AND "+t.column+" IS NULL", pack.ID).
+		Update(t.column, now)
+	if result.Error != nil {
+		log.Printf("Failed to record %s for pack %d: %v", t.event, pack.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return // another instance got there first
+	}
+
+	var at time.Time
+	switch t.at {
+	case "upload_end":
+		at = pack.UploadEnd
+	case "start_date":
+		at = pack.StartDate
+	default:
+		at = pack.EndDate
+	}
+	if now.Sub(at.Add(-t.lead)) > windowEventGrace {
+		return
+	}
+	if t.lead > 0 && !now.Before(at) {
+		return // a reminder for something that already happened
+	}
+
+	log.Printf("Pack %d: %s", pack.ID, t.event)
+	s.events.Publish(t.event, events.NewPackData(pack))
+}
+
+// RunWindowWatcher periodically publishes pack window changes
+func (s *Service) RunWindowWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.PublishWindowChanges(); err != nil {
+			log.Printf("Failed to check pack windows: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// ClosePack deactivates a pack ahead of schedule, publishing that it closed
+// if its submission window had not already ended
+func (s *Service) ClosePack(id uint) (*models.SamplePack, error) {
+	pack, err := s.GetPack(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.GetDB().Model(pack).Update("is_active", false).Error; err != nil {
+		return nil, err
+	}
+	pack.IsActive = false
+
+	now := time.Now()
+	result := db.GetDB().Model(&models.SamplePack{}).
+		Where("id = ? AND closed_at IS NULL", pack.ID).
+		Update("closed_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		pack.ClosedAt = &now
+		s.events.Publish(events.PackClosed, events.NewPackData(pack))
+	}
+	return pack, nil
+}
@@ -0,0 +1,48 @@
+package submission
+
+import (
+	"time"
+
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+)
+
+// IsRevealed reports whether authorship of a pack's submissions is public.
+// Packs without blind listening are always revealed.
+func IsRevealed(pack *models.SamplePack) bool {
+	return !pack.AnonymousSubmissions || !time.Now().Before(pack.RevealTime())
+}
+
+// hideAuthor removes who made submission unless viewer made it, is an
+// admin, or the pack has been revealed. submission.SamplePack must be loaded.
+func hideAuthor(submission *models.Submission, viewer samplepack.Viewer) {
+	if viewer.IsAdmin || submission.UserID == viewer.UserID || IsRevealed(&submission.SamplePack) {
+		return
+	}
+
+	submission.UserID = 0
+	submission.User = nil
+	submission.Anonymous = true
+}
+
+// GetSubmissionFor returns a submission as viewer may see it
+func (s *Service) GetSubmissionFor(id uint, viewer samplepack.Viewer) (*models.Submission, error) {
+	submission, err := s.GetSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	hideAuthor(submission, viewer)
+	return submission, nil
+}
+
+// ListSubmissionsFor returns a pack's submissions as viewer may see them
+func (s *Service) ListSubmissionsFor(packID uint, limit, offset int, viewer samplepack.Viewer) ([]models.Submission, error) {
+	submissions, err := s.ListSubmissions(packID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range submissions {
+		hideAuthor(&submissions[i], viewer)
+	}
+	return submissions, nil
+}
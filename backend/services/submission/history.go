@@ -0,0 +1,56 @@
+package submission
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/samplepack"
+)
+
+// ListUserSubmissions returns the tracks a user submitted that viewer may
+// see, newest first, with how many there are in total. Submissions to
+// packs still in blind listening are left out for other viewers, since
+// listing them under the author would give the author away. Like user
+// sample lists, none are shown to anonymous viewers.
+func (s *Service) ListUserSubmissions(userID uint, viewer samplepack.Viewer, limit, offset int) ([]models.Submission, int64, error) {
+	if viewer.UserID == 0 && !viewer.IsAdmin {
+		return []models.Submission{}, 0, nil
+	}
+
+	var total int64
+	if err := userSubmissionsQuery(userID, viewer).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var submissions []models.Submission
+	err := userSubmissionsQuery(userID, viewer).
+		Preload("SamplePack").
+		Order("submissions.created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&submissions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range submissions {
+		submissions[i].FileURL = fmt.Sprintf("/api/submissions/%d/download", submissions[i].ID)
+		submissions[i].PreviewURL = fmt.Sprintf("/api/submissions/%d/preview", submissions[i].ID)
+	}
+	return submissions, total, nil
+}
+
+// userSubmissionsQuery builds a fresh query each time, since Count and
+// Find cannot share a statement. The reveal condition mirrors IsRevealed.
+func userSubmissionsQuery(userID uint, viewer samplepack.Viewer) *gorm.DB {
+	query := db.GetDB().Model(&models.Submission{}).Where("submissions.user_id = ?", userID)
+	if viewer.UserID != userID && !viewer.IsAdmin {
+		query = query.Joins("JOIN sample_packs ON sample_packs.id = submissions.sample_pack_id").
+			Where("(NOT sample_packs.anonymous_submissions OR COALESCE(sample_packs.reveal_at, sample_packs.end_date) <= ?)", time.Now())
+	}
+	return query
+}
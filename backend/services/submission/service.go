@@ -1,34 +1,60 @@
 package submission
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"sample-exchange/backend/config"
 	"sample-exchange/backend/db"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
 	"sample-exchange/backend/models"
+	"sample-exchange/backend/services/quota"
 	"sample-exchange/backend/services/samplepack"
+	"sample-exchange/backend/storage"
 
 	"gorm.io/gorm"
 )
 
+const JobAnalyzeSubmission = "submission.analyze"
+
 var (
 	ErrSubmissionClosed = errors.New("submission window is closed")
 )
 
+// SubmissionJob is the payload of jobs operating on a single submission
+type SubmissionJob struct {
+	SubmissionID uint `json:"submissionId"`
+}
+
 type Service struct {
 	config      *config.Config
 	packService *samplepack.Service
+	storage     storage.Storage
+	events      *events.Bus
+	quota       *quota.Service
+	analyzeJob  *jobs.JobType[SubmissionJob]
 }
 
-func NewService(cfg *config.Config, packService *samplepack.Service) *Service {
+func NewService(cfg *config.Config, packService *samplepack.Service, store storage.Storage) *Service {
 	return &Service{
 		config:      cfg,
 		packService: packService,
+		storage:     store,
 	}
 }
 
+// RegisterJobs registers the loudness measurement job with q
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.analyzeJob = jobs.Register(q, JobAnalyzeSubmission, 3, func(ctx context.Context, p SubmissionJob) error {
+		return s.AnalyzeSubmission(ctx, p.SubmissionID)
+	})
+}
+
 func (s *Service) CreateSubmission(userID uint, submission *models.Submission) error {
 	if !s.packService.IsSubmissionAllowed() {
 		pack, err := s.packService.GetCurrentPack()
@@ -44,12 +70,105 @@ func (s *Service) CreateSubmission(userID uint, submission *models.Submission) e
 	if err != nil {
 		return err
 	}
+	if currentPack == nil {
+		return fmt.Errorf("no active sample pack found")
+	}
 
 	submission.UserID = userID
 	submission.SamplePackID = currentPack.ID
 	submission.SubmittedAt = time.Now()
 
-	return db.GetDB().Create(submission).Error
+	// Derived works inherit the non-commercial restriction of any sample in the pack
+	nonCommercial, err := s.packService.HasNonCommercialSamples(currentPack.ID)
+	if err != nil {
+		return err
+	}
+	submission.NonCommercial = nonCommercial
+
+	// Loudness is measured and checked against the pack's limits by the
+	// analysis job
+	submission.LoudnessStatus = models.LoudnessPending
+
+	// The quota is checked in the same transaction, so concurrent uploads
+	// cannot both fit under it
+	err = db.GetDB().Transaction(func(tx *gorm.DB) error {
+		if s.quota != nil {
+			if err := s.quota.CheckSubmissionTx(tx, userID, submission.FileSize); err != nil {
+				return err
+			}
+		}
+		return tx.Create(submission).Error
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.analyzeJob.Enqueue(SubmissionJob{SubmissionID: submission.ID}); err != nil {
+		log.Printf("Failed to queue analysis for submission %d: %v", submission.ID, err)
+	}
+
+	// Blind listening packs announce submissions without their author
+	var author *models.User
+	if IsRevealed(currentPack) {
+		var user models.User
+		if err := db.GetDB().First(&user, userID).Error; err == nil {
+			author = &user
+		}
+	}
+	s.events.Publish(events.SubmissionCreated, events.NewSubmissionData(submission, author))
+	return nil
+}
+
+// AnalyzeSubmission measures the loudness of a new submission and removes
+// it if it exceeds its pack's limits, telling the author why
+func (s *Service) AnalyzeSubmission(ctx context.Context, submissionID uint) error {
+	var submission models.Submission
+	if err := db.GetDB().Preload("SamplePack").First(&submission, submissionID).Error; err != nil {
+		return err
+	}
+	if submission.LoudnessStatus != models.LoudnessPending {
+		return nil
+	}
+
+	loudness, err := samplepack.MeasureLoudness(ctx, s.storage, submission.FilePath)
+	if err != nil {
+		return err
+	}
+	violations := samplepack.LoudnessViolations(&submission.SamplePack, loudness)
+	if len(violations) > 0 {
+		loudness.LoudnessStatus = models.LoudnessRejected
+	}
+	if err := db.GetDB().Model(&submission).Updates(samplepack.LoudnessUpdates(loudness)).Error; err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if err := db.GetDB().Delete(&submission).Error; err != nil {
+		return err
+	}
+	reason := strings.Join(violations, "; ")
+	log.Printf("Rejected submission %d: %s", submission.ID, reason)
+	s.events.Publish(events.UploadRejected, events.UploadRejectedData{
+		Kind:     "submission",
+		ID:       submission.ID,
+		PackID:   submission.SamplePackID,
+		UserID:   submission.UserID,
+		Filename: submission.Filename,
+		Reason:   reason,
+	})
+	return nil
+}
+
+// SetEventBus makes the service publish submission events to bus
+func (s *Service) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
+// SetQuotaService makes the service enforce upload quotas when creating
+// submissions
+func (s *Service) SetQuotaService(q *quota.Service) {
+	s.quota = q
 }
 
 func (s *Service) GetSubmission(id uint) (*models.Submission, error) {
@@ -67,6 +186,13 @@ func (s *Service) GetSubmission(id uint) (*models.Submission, error) {
 	}
 
 	submission.FileURL = fmt.Sprintf("/api/submissions/%d/download", submission.ID)
+	submission.PreviewURL = fmt.Sprintf("/api/submissions/%d/preview", submission.ID)
+
+	credits, err := s.packService.GetSampleCredits(submission.SamplePackID)
+	if err != nil {
+		return nil, err
+	}
+	submission.SampleCredits = credits
 
 	return &submission, nil
 }
@@ -108,6 +234,7 @@ func (s *Service) ListSubmissions(packID uint, limit, offset int) ([]models.Subm
 	// Generate file URLs for submissions
 	for i := range submissions {
 		submissions[i].FileURL = fmt.Sprintf("/api/submissions/%d/download", submissions[i].ID)
+		submissions[i].PreviewURL = fmt.Sprintf("/api/submissions/%d/preview", submissions[i].ID)
 	}
 
 	return submissions, nil
@@ -0,0 +1,242 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+	"sample-exchange/backend/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Expiry is how long an unfinished upload is kept
+	Expiry = 24 * time.Hour
+
+	JobExpireUpload = "upload.expire"
+)
+
+var ErrUploadCompleted = stderrors.New("upload already completed")
+
+// UploadJob is the payload of jobs operating on a resumable upload
+type UploadJob struct {
+	ID string `json:"id"`
+}
+
+type Service struct {
+	storage   storage.Storage
+	expireJob *jobs.JobType[UploadJob]
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewService(store storage.Storage) *Service {
+	return &Service{
+		storage: store,
+		locks:   make(map[string]*sync.Mutex),
+	}
+}
+
+// RegisterJobs registers the job that removes abandoned uploads
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.expireJob = jobs.Register(q, JobExpireUpload, jobs.DefaultMaxAttempts, func(ctx context.Context, p UploadJob) error {
+		return s.Expire(p.ID)
+	})
+}
+
+// Create starts a new resumable upload for userID
+func (s *Service) Create(userID uint, kind string, packID uint, filename string, length int64, metadata map[string]string) (*models.Upload, error) {
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &models.Upload{
+		ID:           id,
+		UserID:       userID,
+		Kind:         kind,
+		SamplePackID: packID,
+		Filename:     filename,
+		Length:       length,
+		Metadata:     string(raw),
+		ExpiresAt:    time.Now().Add(Expiry),
+	}
+
+	if err := s.storage.CreateUpload(id); err != nil {
+		return nil, err
+	}
+	if err := db.GetDB().Create(upload).Error; err != nil {
+		s.storage.DeleteUpload(id) // Clean up on error
+		return nil, err
+	}
+
+	if _, err := s.expireJob.EnqueueAt(UploadJob{ID: id}, "", upload.ExpiresAt); err != nil {
+		log.Printf("Failed to schedule expiry of upload %s: %v", id, err)
+	}
+	return upload, nil
+}
+
+// Get returns an upload owned by userID
+func (s *Service) Get(id string, userID uint) (*models.Upload, error) {
+	var upload models.Upload
+	err := db.GetDB().Where("id = ? AND user_id = ? AND expires_at > ?", id, userID, time.Now()).First(&upload).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Upload")
+	}
+	return &upload, err
+}
+
+// Metadata decodes the metadata the upload was created with
+func Metadata(upload *models.Upload) map[string]string {
+	metadata := map[string]string{}
+	if err := json.Unmarshal([]byte(upload.Metadata), &metadata); err != nil {
+		log.Printf("Failed to decode metadata of upload %s: %v", upload.ID, err)
+	}
+	return metadata
+}
+
+// Append writes chunk at offset and records the new offset. Chunks beyond
+// the declared length are truncated.
+func (s *Service) Append(upload *models.Upload, offset int64, chunk io.Reader) error {
+	lock := s.lock(upload.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if upload.CompletedAt != nil {
+		return ErrUploadCompleted
+	}
+
+	written, copyErr := s.storage.AppendUpload(upload.ID, offset, io.LimitReader(chunk, upload.Length-offset))
+	if stderrors.Is(copyErr, storage.ErrUploadOffset) {
+		return copyErr
+	}
+
+	if written > 0 {
+		upload.Offset = offset + written
+		if err := db.GetDB().Model(upload).Update("offset", upload.Offset).Error; err != nil {
+			return err
+		}
+	}
+	return copyErr
+}
+
+// Claim marks a fully received upload as being finalized. It returns false
+// if another request already claimed it.
+func (s *Service) Claim(upload *models.Upload) (bool, error) {
+	if upload.Offset != upload.Length {
+		return false, nil
+	}
+
+	now := time.Now()
+	result := db.GetDB().Model(&models.Upload{}).
+		Where("id = ? AND completed_at IS NULL", upload.ID).
+		Update("completed_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	upload.CompletedAt = &now
+	return true, nil
+}
+
+// Release undoes a Claim so finalization can be retried
+func (s *Service) Release(upload *models.Upload) error {
+	upload.CompletedAt = nil
+	return db.GetDB().Model(upload).Update("completed_at", nil).Error
+}
+
+// Open returns the assembled file of an upload
+func (s *Service) Open(upload *models.Upload) (io.ReadCloser, error) {
+	return s.storage.OpenUpload(upload.ID)
+}
+
+// Complete records what a finalized upload produced and discards its chunks.
+// The record is kept until expiry so clients can still query its status.
+func (s *Service) Complete(upload *models.Upload, sampleID, submissionID *uint) error {
+	upload.SampleID = sampleID
+	upload.SubmissionID = submissionID
+	if err := db.GetDB().Model(upload).Updates(map[string]interface{}{
+		"sample_id":     sampleID,
+		"submission_id": submissionID,
+	}).Error; err != nil {
+		return err
+	}
+
+	s.unlock(upload.ID)
+	return s.storage.DeleteUpload(upload.ID)
+}
+
+// Delete terminates an upload and removes its data
+func (s *Service) Delete(upload *models.Upload) error {
+	lock := s.lock(upload.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := s.storage.DeleteUpload(upload.ID); err != nil {
+		return err
+	}
+	s.unlock(upload.ID)
+	return db.GetDB().Delete(upload).Error
+}
+
+// Expire removes an upload once it has passed its expiry time
+func (s *Service) Expire(id string) error {
+	var upload models.Upload
+	err := db.GetDB().Where("id = ?", id).First(&upload).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if upload.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return s.Delete(&upload)
+}
+
+func (s *Service) lock(id string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, ok := s.locks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[id] = lock
+	}
+	return lock
+}
+
+func (s *Service) unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, id)
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	apierrors "sample-exchange/backend/errors"
+	"sample-exchange/backend/models"
+)
+
+const (
+	maxNameLength   = 50
+	maxBioLength    = 1000
+	maxLinks        = 5
+	maxAvatarLength = 2048
+)
+
+// Profile is what other users see of a user
+type Profile struct {
+	ID       uint         `json:"ID"`
+	Name     string       `json:"name"`
+	Avatar   string       `json:"avatar"`
+	Bio      string       `json:"bio"`
+	Links    models.Links `json:"links"`
+	JoinedAt time.Time    `json:"joinedAt"`
+}
+
+func NewProfile(user *models.User) *Profile {
+	links := user.Links
+	if links == nil {
+		links = models.Links{}
+	}
+	return &Profile{
+		ID:       user.ID,
+		Name:     user.Name,
+		Avatar:   user.Avatar,
+		Bio:      user.Bio,
+		Links:    links,
+		JoinedAt: user.CreatedAt,
+	}
+}
+
+// ProfileInput holds changes to a user's profile. Nil fields are left
+// unchanged.
+type ProfileInput struct {
+	Name   *string   `json:"name"`
+	Avatar *string   `json:"avatar"` // image URL, or empty for none
+	Bio    *string   `json:"bio"`
+	Links  *[]string `json:"links"`
+}
+
+// UpdateProfile validates and applies profile changes
+func (s *Service) UpdateProfile(id uint, input ProfileInput) (*models.User, error) {
+	user, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apierrors.NewNotFoundError("User")
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			return nil, apierrors.NewValidationError("name", "Name must be 1 to 50 characters")
+		}
+		updates["name"] = name
+		updates["custom_name"] = true
+	}
+	if input.Avatar != nil {
+		avatar := strings.TrimSpace(*input.Avatar)
+		if avatar != "" {
+			u, ok := httpURL(avatar)
+			if !ok || len(avatar) > maxAvatarLength {
+				return nil, apierrors.NewValidationError("avatar", "Avatar must be an http or https URL")
+			}
+			avatar = u.String()
+		}
+		updates["avatar"] = avatar
+		updates["custom_avatar"] = true
+	}
+	if input.Bio != nil {
+		bio := strings.TrimSpace(*input.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return nil, apierrors.NewValidationError("bio", "Bio must be at most 1000 characters")
+		}
+		updates["bio"] = bio
+	}
+	if input.Links != nil {
+		links, err := normalizeLinks(*input.Links)
+		if err != nil {
+			return nil, err
+		}
+		updates["links"] = links
+	}
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	if err := s.db.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.GetByID(id)
+}
+
+// normalizeLinks checks that links are absolute http or https URLs,
+// dropping empty entries
+func normalizeLinks(raw []string) (models.Links, error) {
+	links := models.Links{}
+	for _, link := range raw {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		u, ok := httpURL(link)
+		if !ok {
+			return nil, apierrors.NewValidationError("links", "Links must be http or https URLs")
+		}
+		links = append(links, u.String())
+	}
+	if len(links) > maxLinks {
+		return nil, apierrors.NewValidationError("links", "At most 5 links are allowed")
+	}
+	return links, nil
+}
+
+// httpURL parses an absolute http or https URL
+func httpURL(raw string) (*url.URL, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
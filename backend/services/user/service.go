@@ -72,9 +72,13 @@ func (s *Service) GetOrCreateOAuthUser(email, name, provider, avatar string) (*m
 		}
 	} else {
 		// Update existing user's OAuth info
-		user.Name = name
+		if !user.CustomName {
+			user.Name = name
+		}
 		user.Provider = provider
-		user.Avatar = avatar
+		if !user.CustomAvatar {
+			user.Avatar = avatar
+		}
 		user.IsAdmin = provider == "dev" // make dev users admins
 		if err := s.db.Save(&user).Error; err != nil {
 			return nil, err
@@ -0,0 +1,398 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"sample-exchange/backend/db"
+	"sample-exchange/backend/errors"
+	"sample-exchange/backend/events"
+	"sample-exchange/backend/jobs"
+	"sample-exchange/backend/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	JobDeliver = "webhook.deliver"
+
+	// PingEvent is sent on request to check that a receiver is reachable
+	PingEvent = "ping"
+
+	// MaxAttempts is how often a delivery is tried before it is marked failed
+	MaxAttempts = 8
+
+	deliveryTimeout = 10 * time.Second
+	maxResponseBody = 1024
+)
+
+// DeliveryJob is the payload of webhook delivery jobs
+type DeliveryJob struct {
+	DeliveryID uint `json:"deliveryId"`
+}
+
+type Service struct {
+	client     *http.Client
+	deliverJob *jobs.JobType[DeliveryJob]
+}
+
+func NewService() *Service {
+	return &Service{
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+}
+
+// RegisterJobs registers the delivery job with q. Retries back off
+// exponentially through the queue.
+func (s *Service) RegisterJobs(q *jobs.Queue) {
+	s.deliverJob = jobs.Register(q, JobDeliver, MaxAttempts, func(ctx context.Context, p DeliveryJob) error {
+		return s.Deliver(ctx, p.DeliveryID)
+	})
+}
+
+// Subscribe queues a delivery to every matching webhook for each event
+// published on bus
+func (s *Service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(func(event events.Event) {
+		if err := s.dispatch(event); err != nil {
+			log.Printf("Failed to queue webhooks for %s: %v", event.Type, err)
+		}
+	})
+}
+
+func (s *Service) dispatch(event events.Event) error {
+	if !events.IsType(event.Type) {
+		return nil // transient events such as countdown ticks
+	}
+
+	var hooks []models.Webhook
+	if err := db.GetDB().Where("active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		if !subscribed(&hook, event.Type) {
+			continue
+		}
+		if _, err := s.queueDelivery(hook.ID, event.Type, string(payload), nil); err != nil {
+			log.Printf("Failed to queue %s for webhook %d: %v", event.Type, hook.ID, err)
+		}
+	}
+	return nil
+}
+
+func subscribed(hook *models.Webhook, eventType string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) queueDelivery(webhookID uint, eventType, payload string, redeliveryOf *uint) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		WebhookID:    webhookID,
+		Event:        eventType,
+		Payload:      payload,
+		Status:       models.DeliveryPending,
+		RedeliveryOf: redeliveryOf,
+	}
+	if err := db.GetDB().Create(delivery).Error; err != nil {
+		return nil, err
+	}
+
+	if _, err := s.deliverJob.Enqueue(DeliveryJob{DeliveryID: delivery.ID}); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Deliver makes one attempt at sending a delivery, recording the response.
+// It returns an error for the queue to retry unless the receiver answered
+// with a 2xx status.
+func (s *Service) Deliver(ctx context.Context, deliveryID uint) error {
+	var delivery models.WebhookDelivery
+	if err := db.GetDB().First(&delivery, deliveryID).Error; err != nil {
+		return err
+	}
+	if delivery.Status != models.DeliveryPending {
+		return nil
+	}
+
+	var hook models.Webhook
+	if err := db.GetDB().Unscoped().First(&hook, delivery.WebhookID).Error; err != nil {
+		return err
+	}
+	if !hook.Active || hook.DeletedAt.Valid {
+		return s.finish(&delivery, models.DeliveryFailed, map[string]interface{}{"last_error": "webhook disabled"})
+	}
+
+	status, body, sendErr := s.send(ctx, &hook, &delivery)
+	updates := map[string]interface{}{
+		"attempts":        delivery.Attempts + 1,
+		"response_status": status,
+		"response_body":   body,
+		"last_error":      "",
+	}
+	if sendErr == nil && (status < 200 || status > 299) {
+		sendErr = fmt.Errorf("receiver answered %d", status)
+	}
+
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		updates["delivered_at"] = &now
+		return s.finish(&delivery, models.DeliverySucceeded, updates)
+	case delivery.Attempts+1 >= MaxAttempts:
+		updates["last_error"] = sendErr.Error()
+		s.finish(&delivery, models.DeliveryFailed, updates)
+		return sendErr
+	default:
+		updates["last_error"] = sendErr.Error()
+		if err := db.GetDB().Model(&delivery).Updates(updates).Error; err != nil {
+			log.Printf("Failed to record attempt of webhook delivery %d: %v", delivery.ID, err)
+		}
+		return sendErr
+	}
+}
+
+func (s *Service) finish(delivery *models.WebhookDelivery, status string, updates map[string]interface{}) error {
+	updates["status"] = status
+	return db.GetDB().Model(delivery).Updates(updates).Error
+}
+
+// send posts the signed payload and returns the response status and the
+// start of its body
+func (s *Service) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quixit-webhooks/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(excerpt), nil
+}
+
+// WebhookInput holds the editable fields of a webhook. Nil fields are left
+// unchanged on update.
+type WebhookInput struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Description *string   `json:"description"`
+	Active      *bool     `json:"active"`
+}
+
+// Create adds a webhook and returns it with its signing secret, which is not
+// shown again
+func (s *Service) Create(input WebhookInput) (*models.Webhook, error) {
+	if input.URL == nil {
+		return nil, errors.NewValidationError("url", "URL is required")
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	hook := &models.Webhook{Secret: secret, Active: true, Events: models.Tags{}}
+	if err := applyInput(hook, input); err != nil {
+		return nil, err
+	}
+
+	if err := db.GetDB().Create(hook).Error; err != nil {
+		return nil, err
+	}
+	if !hook.Active {
+		// gorm skips false for columns with a default
+		db.GetDB().Model(hook).Update("active", false)
+	}
+	return hook, nil
+}
+
+// Update changes the fields of a webhook set in input
+func (s *Service) Update(id uint, input WebhookInput) (*models.Webhook, error) {
+	hook, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyInput(hook, input); err != nil {
+		return nil, err
+	}
+
+	if err := db.GetDB().Model(hook).Select("url", "events", "description", "active").Updates(hook).Error; err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func applyInput(hook *models.Webhook, input WebhookInput) error {
+	if input.URL != nil {
+		u, err := url.Parse(*input.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.NewValidationError("url", "URL must be an absolute http or https URL")
+		}
+		hook.URL = u.String()
+	}
+	if input.Events != nil {
+		subscribed := models.Tags{}
+		for _, e := range *input.Events {
+			if !events.IsType(e) {
+				return errors.NewValidationError("events", fmt.Sprintf("Unknown event %q", e))
+			}
+			subscribed = append(subscribed, e)
+		}
+		hook.Events = subscribed
+	}
+	if input.Description != nil {
+		hook.Description = *input.Description
+	}
+	if input.Active != nil {
+		hook.Active = *input.Active
+	}
+	return nil
+}
+
+// RotateSecret replaces the signing secret of a webhook and returns it
+func (s *Service) RotateSecret(id uint) (*models.Webhook, error) {
+	hook, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if hook.Secret, err = newSecret(); err != nil {
+		return nil, err
+	}
+	if err := db.GetDB().Model(hook).Update("secret", hook.Secret).Error; err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Get returns a webhook by ID
+func (s *Service) Get(id uint) (*models.Webhook, error) {
+	var hook models.Webhook
+	err := db.GetDB().First(&hook, id).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Webhook")
+	}
+	return &hook, err
+}
+
+// List returns every webhook
+func (s *Service) List() ([]models.Webhook, error) {
+	var hooks []models.Webhook
+	err := db.GetDB().Order("id").Find(&hooks).Error
+	return hooks, err
+}
+
+// Delete removes a webhook. Pending deliveries to it are marked failed when
+// their job runs.
+func (s *Service) Delete(id uint) error {
+	result := db.GetDB().Delete(&models.Webhook{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("Webhook")
+	}
+	return nil
+}
+
+// Ping queues a ping delivery to check that a webhook's receiver works
+func (s *Service) Ping(id uint) (*models.WebhookDelivery, error) {
+	hook, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(events.Event{
+		Type: PingEvent,
+		Time: time.Now(),
+		Data: map[string]interface{}{"webhookId": hook.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.queueDelivery(hook.ID, PingEvent, string(payload), nil)
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first
+func (s *Service) ListDeliveries(webhookID uint, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	query := db.GetDB().Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetDelivery returns a delivery by ID
+func (s *Service) GetDelivery(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := db.GetDB().First(&delivery, id).Error
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.NewNotFoundError("Delivery")
+	}
+	return &delivery, err
+}
+
+// Redeliver sends the payload of an earlier delivery again as a new
+// delivery, with a fresh timestamp and signature
+func (s *Service) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	original, err := s.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status == models.DeliveryPending {
+		return nil, errors.NewValidationError("status", "Delivery is still pending")
+	}
+	if _, err := s.Get(original.WebhookID); err != nil {
+		return nil, err
+	}
+
+	return s.queueDelivery(original.WebhookID, original.Event, original.Payload, &original.ID)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
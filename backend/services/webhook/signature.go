@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Quixit-Event"
+	DeliveryHeader  = "X-Quixit-Delivery"
+	TimestampHeader = "X-Quixit-Timestamp"
+	SignatureHeader = "X-Quixit-Signature"
+)
+
+var (
+	ErrInvalidSignature = stderrors.New("webhook signature does not match")
+	ErrStaleTimestamp   = stderrors.New("webhook timestamp is too old")
+)
+
+// Sign returns the signature header value for body sent at timestamp. The
+// HMAC-SHA256 covers "<timestamp>.<body>" so a captured request cannot be
+// replayed with a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received delivery.
+// Receivers should reject requests older than tolerance.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(ts, 0)) > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := Sign(secret, ts, body)
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,571 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Encrypted files start with a fixed-size header holding the file's data
+// key, wrapped with a master key, followed by the contents split into
+// segments that are sealed separately so they can be read at any offset.
+const (
+	encryptionMagic = "QXE1"
+	keyIDSize       = 8
+	keySize         = 32
+	wrapNonceSize   = 12
+	noncePrefixSize = 8
+	headerSize      = len(encryptionMagic) + keyIDSize + wrapNonceSize + keySize + 16 + noncePrefixSize
+
+	segmentSize       = 64 * 1024
+	sealedSegmentSize = segmentSize + 16
+
+	// staleDecryptedAge is how old a decrypted copy must be before it is
+	// assumed to be left over from a crash and removed at startup
+	staleDecryptedAge = 24 * time.Hour
+)
+
+var (
+	ErrUnknownKey    = errors.New("file is encrypted with an unknown master key")
+	ErrInvalidKey    = errors.New("master key must be 32 bytes, base64 encoded")
+	ErrCorruptCipher = errors.New("encrypted file is corrupt or has been tampered with")
+)
+
+// replacer is implemented by storages that can atomically overwrite a file
+type replacer interface {
+	Replace(path string, file io.Reader) error
+}
+
+// EncryptedStorage encrypts files before handing them to the underlying
+// storage and decrypts them again on Open. Each file gets its own random
+// data key, which is stored in the file header wrapped with the master key.
+// Files saved before encryption was enabled are read as they are.
+// Resumable uploads are only kept until they complete and are not encrypted.
+type EncryptedStorage struct {
+	Storage
+
+	workDir  string // private directory for decrypted copies, see LocalPath
+	keyID    []byte
+	master   cipher.AEAD
+	previous map[string]cipher.AEAD // by hex key ID, for files not yet re-wrapped
+}
+
+// NewEncryptedStorage wraps base with envelope encryption under master.
+// Previous master keys can still decrypt files until they are re-wrapped.
+// Decrypted copies for LocalPath are written to workDir, which is created
+// readable only by the server's user.
+func NewEncryptedStorage(base Storage, workDir string, master []byte, previous ...[]byte) (*EncryptedStorage, error) {
+	aead, err := newAEAD(master)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareWorkDir(workDir); err != nil {
+		return nil, err
+	}
+
+	s := &EncryptedStorage{
+		Storage:  base,
+		workDir:  workDir,
+		keyID:    masterKeyID(master),
+		master:   aead,
+		previous: make(map[string]cipher.AEAD),
+	}
+	for _, key := range previous {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		s.previous[hex.EncodeToString(masterKeyID(key))] = aead
+	}
+	return s, nil
+}
+
+// ParseMasterKey decodes a base64 encoded 32 byte master key
+func ParseMasterKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+func (s *EncryptedStorage) SaveSample(file io.Reader, filename string) (*StoredFile, error) {
+	return s.save(s.Storage.SaveSample, file, filename)
+}
+
+func (s *EncryptedStorage) SaveSubmission(file io.Reader, filename string) (*StoredFile, error) {
+	return s.save(s.Storage.SaveSubmission, file, filename)
+}
+
+func (s *EncryptedStorage) SaveArchive(file io.Reader, filename string) (*StoredFile, error) {
+	return s.save(s.Storage.SaveArchive, file, filename)
+}
+
+func (s *EncryptedStorage) SavePreview(file io.Reader, filename string) (*StoredFile, error) {
+	return s.save(s.Storage.SavePreview, file, filename)
+}
+
+// save encrypts file on its way to the underlying storage. The returned
+// size and checksum describe the plaintext.
+func (s *EncryptedStorage) save(saveFn func(io.Reader, string) (*StoredFile, error), file io.Reader, filename string) (*StoredFile, error) {
+	enc, err := s.newEncryptReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := saveFn(enc, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredFile{Path: stored.Path, Size: enc.size, SHA256: hex.EncodeToString(enc.hash.Sum(nil))}, nil
+}
+
+// Open returns a reader over the decrypted contents of a stored file
+func (s *EncryptedStorage) Open(filepath string) (io.ReadSeekCloser, error) {
+	f, err := s.Storage.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := readHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if header == nil {
+		// Stored before encryption was enabled
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	r, err := s.newDecryptReader(f, header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// LocalPath decrypts a stored file into a temporary file for tools that need
+// a path. The release function removes the temporary copy.
+//
+// The copy is plaintext on disk until it is released. It is kept in the
+// work directory under the storage root rather than the shared temporary
+// directory, readable only by the server's user, but anyone with that
+// user's or root's access can read it meanwhile, and a copy left by a
+// crash stays until startup clears it a day later.
+func (s *EncryptedStorage) LocalPath(path string) (string, func(), error) {
+	src, err := s.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(s.workDir, "decrypted_*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return tmp.Name(), release, nil
+}
+
+// prepareWorkDir creates dir for decrypted copies, or tightens its
+// permissions if it exists, and removes copies left over from crashes
+func prepareWorkDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleDecryptedAge {
+			continue
+		}
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+	return nil
+}
+
+// RewrapReport summarizes a master key rotation
+type RewrapReport struct {
+	Rewrapped []string `json:"rewrapped"`
+	Current   int      `json:"current"`   // already wrapped with the current key
+	Plaintext []string `json:"plaintext"` // stored before encryption was enabled
+	Failed    []string `json:"failed"`
+}
+
+// Rewrap re-encrypts the data key of every stored file with the current
+// master key. File contents are not re-encrypted, so previous keys can be
+// retired once Rewrap reports no failures.
+func (s *EncryptedStorage) Rewrap() (*RewrapReport, error) {
+	replacer, ok := s.Storage.(replacer)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support replacing files")
+	}
+
+	files, err := s.Storage.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RewrapReport{Rewrapped: []string{}, Plaintext: []string{}, Failed: []string{}}
+	for _, file := range files {
+		encrypted, rewrapped, err := s.rewrapFile(replacer, file.Path)
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", file.Path, err))
+		case !encrypted:
+			report.Plaintext = append(report.Plaintext, file.Path)
+		case rewrapped:
+			report.Rewrapped = append(report.Rewrapped, file.Path)
+		default:
+			report.Current++
+		}
+	}
+	return report, nil
+}
+
+// rewrapFile replaces the header of one file if it was wrapped with a
+// previous master key
+func (s *EncryptedStorage) rewrapFile(replacer replacer, path string) (encrypted, rewrapped bool, err error) {
+	f, err := s.Storage.Open(path)
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil || header == nil {
+		return false, false, err
+	}
+	if bytes.Equal(header.keyID, s.keyID) {
+		return true, false, nil
+	}
+
+	dataKey, err := s.unwrap(header)
+	if err != nil {
+		return true, false, err
+	}
+	if header, err = s.wrap(dataKey, header.noncePrefix); err != nil {
+		return true, false, err
+	}
+
+	// The rest of f is positioned just past the old header
+	if err := replacer.Replace(path, io.MultiReader(bytes.NewReader(header.encode()), f)); err != nil {
+		return true, false, err
+	}
+	return true, true, nil
+}
+
+type fileHeader struct {
+	keyID       []byte
+	wrapNonce   []byte
+	wrappedKey  []byte
+	noncePrefix []byte
+}
+
+func (h *fileHeader) encode() []byte {
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, encryptionMagic...)
+	buf = append(buf, h.keyID...)
+	buf = append(buf, h.wrapNonce...)
+	buf = append(buf, h.wrappedKey...)
+	return append(buf, h.noncePrefix...)
+}
+
+// readHeader reads an encryption header from r, returning nil if r does not
+// start with one
+func readHeader(r io.Reader) (*fileHeader, error) {
+	buf := make([]byte, headerSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if n < headerSize || string(buf[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, nil
+	}
+
+	buf = buf[len(encryptionMagic):]
+	return &fileHeader{
+		keyID:       buf[:keyIDSize],
+		wrapNonce:   buf[keyIDSize : keyIDSize+wrapNonceSize],
+		wrappedKey:  buf[keyIDSize+wrapNonceSize : keyIDSize+wrapNonceSize+keySize+16],
+		noncePrefix: buf[keyIDSize+wrapNonceSize+keySize+16:],
+	}, nil
+}
+
+// wrap seals a data key with the current master key
+func (s *EncryptedStorage) wrap(dataKey, noncePrefix []byte) (*fileHeader, error) {
+	header := &fileHeader{keyID: s.keyID, wrapNonce: make([]byte, wrapNonceSize), noncePrefix: noncePrefix}
+	if _, err := rand.Read(header.wrapNonce); err != nil {
+		return nil, err
+	}
+	header.wrappedKey = s.master.Seal(nil, header.wrapNonce, dataKey, header.keyID)
+	return header, nil
+}
+
+// unwrap opens a data key with whichever master key wrapped it
+func (s *EncryptedStorage) unwrap(header *fileHeader) ([]byte, error) {
+	master := s.master
+	if !bytes.Equal(header.keyID, s.keyID) {
+		var ok bool
+		if master, ok = s.previous[hex.EncodeToString(header.keyID)]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	dataKey, err := master.Open(nil, header.wrapNonce, header.wrappedKey, header.keyID)
+	if err != nil {
+		return nil, ErrCorruptCipher
+	}
+	return dataKey, nil
+}
+
+// encryptReader yields the header followed by the sealed segments of src
+type encryptReader struct {
+	src         *bufio.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	segment     uint32
+	pending     []byte
+	done        bool
+
+	hash hash.Hash
+	size int64
+}
+
+func (s *EncryptedStorage) newEncryptReader(src io.Reader) (*encryptReader, error) {
+	dataKey := make([]byte, keySize)
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header, err := s.wrap(dataKey, noncePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptReader{
+		src:         bufio.NewReaderSize(src, segmentSize),
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		pending:     header.encode(),
+		hash:        sha256.New(),
+	}, nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// sealNext encrypts the next segment. The last segment is marked so a
+// truncated file fails to decrypt.
+func (r *encryptReader) sealNext() error {
+	plain := make([]byte, segmentSize)
+	n, err := io.ReadFull(r.src, plain)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	plain = plain[:n]
+
+	final := err != nil
+	if !final {
+		if _, err := r.src.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.hash.Write(plain)
+	r.size += int64(n)
+	r.pending = r.aead.Seal(nil, segmentNonce(r.noncePrefix, r.segment), plain, segmentAAD(final))
+	r.segment++
+	r.done = final
+	return nil
+}
+
+// decryptReader decrypts segments of an encrypted file on demand
+type decryptReader struct {
+	f           io.ReadSeekCloser
+	aead        cipher.AEAD
+	noncePrefix []byte
+	segments    int64
+	size        int64
+	pos         int64
+
+	cached int64 // index of the decrypted segment in plain, or -1
+	plain  []byte
+}
+
+func (s *EncryptedStorage) newDecryptReader(f io.ReadSeekCloser, header *fileHeader) (*decryptReader, error) {
+	dataKey, err := s.unwrap(header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	body := total - int64(headerSize)
+	segments := (body + sealedSegmentSize - 1) / sealedSegmentSize
+	last := body - (segments-1)*sealedSegmentSize
+	if segments == 0 || last < 16 {
+		return nil, ErrCorruptCipher
+	}
+
+	return &decryptReader{
+		f:           f,
+		aead:        aead,
+		noncePrefix: header.noncePrefix,
+		segments:    segments,
+		size:        (segments-1)*segmentSize + last - 16,
+		cached:      -1,
+	}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.pos / segmentSize
+	if index != r.cached {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain[r.pos-index*segmentSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *decryptReader) load(index int64) error {
+	if _, err := r.f.Seek(int64(headerSize)+index*sealedSegmentSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	sealed := make([]byte, sealedSegmentSize)
+	n, err := io.ReadFull(r.f, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	final := index == r.segments-1
+	plain, err := r.aead.Open(nil, segmentNonce(r.noncePrefix, uint32(index)), sealed[:n], segmentAAD(final))
+	if err != nil {
+		return ErrCorruptCipher
+	}
+	r.plain = plain
+	r.cached = index
+	return nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.f.Close()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// masterKeyID identifies a master key in file headers without revealing it
+func masterKeyID(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("quixit master key "), key...))
+	return sum[:keyIDSize]
+}
+
+func segmentNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], index)
+	return nonce
+}
+
+func segmentAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
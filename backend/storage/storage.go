@@ -1,47 +1,244 @@
 package storage
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"sample-exchange/backend/config"
 )
 
 type Storage interface {
-	SaveSample(file io.Reader, filename string) (string, error)
-	SaveSubmission(file io.Reader, filename string) (string, error)
+	SaveSample(file io.Reader, filename string) (*StoredFile, error)
+	SaveSubmission(file io.Reader, filename string) (*StoredFile, error)
+	SaveArchive(file io.Reader, filename string) (*StoredFile, error)
+	SavePreview(file io.Reader, filename string) (*StoredFile, error)
+	Open(filepath string) (io.ReadSeekCloser, error)
 	Delete(filepath string) error
+
+	// LocalPath returns a path on local disk holding the plain contents of a
+	// stored file, for tools such as ffmpeg that cannot read from Open.
+	// release must be called once the path is no longer needed.
+	LocalPath(filepath string) (path string, release func(), err error)
+
+	// ListFiles returns every stored sample, submission, archive and preview
+	ListFiles() ([]FileInfo, error)
+	Exists(filepath string) (bool, error)
+
+	// Resumable uploads are assembled chunk by chunk before being saved
+	CreateUpload(id string) error
+	AppendUpload(id string, offset int64, chunk io.Reader) (int64, error)
+	OpenUpload(id string) (io.ReadCloser, error)
+	DeleteUpload(id string) error
+}
+
+var (
+	ErrNotExist         = fs.ErrNotExist
+	ErrUploadOffset     = errors.New("upload offset does not match stored size")
+	ErrChecksumMismatch = errors.New("stored file does not match its checksum")
+	ErrInvalidFilename  = errors.New("invalid filename")
+)
+
+// StoredFile describes a file written to storage. SHA256 is the hex digest
+// of the contents as they were saved.
+type StoredFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
 }
 
 type FileStorage struct {
 	samplePath     string
 	submissionPath string
+	archivePath    string
+	previewPath    string
+	uploadPath     string
 }
 
-func NewStorage(cfg *config.Config) Storage {
-	return &FileStorage{
+// NewStorage returns the file store under cfg.StoragePath, encrypted at rest
+// when a master key is configured
+func NewStorage(cfg *config.Config) (Storage, error) {
+	store := &FileStorage{
 		samplePath:     filepath.Join(cfg.StoragePath, "samples"),
 		submissionPath: filepath.Join(cfg.StoragePath, "submissions"),
+		archivePath:    filepath.Join(cfg.StoragePath, "archives"),
+		previewPath:    filepath.Join(cfg.StoragePath, "previews"),
+		uploadPath:     filepath.Join(cfg.StoragePath, "uploads"),
+	}
+	if cfg.EncryptionKey == "" {
+		return store, nil
 	}
+
+	master, err := ParseMasterKey(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	var previous [][]byte
+	for _, value := range cfg.OldEncryptionKeys {
+		key, err := ParseMasterKey(value)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return NewEncryptedStorage(store, filepath.Join(cfg.StoragePath, "decrypted"), master, previous...)
 }
 
-func (s *FileStorage) SaveSample(file io.Reader, filename string) (string, error) {
+func (s *FileStorage) SaveSample(file io.Reader, filename string) (*StoredFile, error) {
 	return s.saveFile(s.samplePath, file, filename)
 }
 
-func (s *FileStorage) SaveSubmission(file io.Reader, filename string) (string, error) {
+func (s *FileStorage) SaveSubmission(file io.Reader, filename string) (*StoredFile, error) {
 	return s.saveFile(s.submissionPath, file, filename)
 }
 
+func (s *FileStorage) SaveArchive(file io.Reader, filename string) (*StoredFile, error) {
+	return s.saveFile(s.archivePath, file, filename)
+}
+
+func (s *FileStorage) SavePreview(file io.Reader, filename string) (*StoredFile, error) {
+	return s.saveFile(s.previewPath, file, filename)
+}
+
+func (s *FileStorage) Open(filepath string) (io.ReadSeekCloser, error) {
+	return os.Open(filepath)
+}
+
 func (s *FileStorage) Delete(filepath string) error {
 	return os.Remove(filepath)
 }
 
-func (s *FileStorage) saveFile(basePath string, file io.Reader, filename string) (string, error) {
+func (s *FileStorage) LocalPath(filepath string) (string, func(), error) {
+	return filepath, func() {}, nil
+}
+
+// Replace atomically overwrites an existing file with the contents of file
+func (s *FileStorage) Replace(path string, file io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, file)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStorage) ListFiles() ([]FileInfo, error) {
+	var files []FileInfo
+	for _, dir := range []string{s.samplePath, s.submissionPath, s.archivePath, s.previewPath} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == dir {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			files = append(files, FileInfo{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (s *FileStorage) Exists(filepath string) (bool, error) {
+	_, err := os.Stat(filepath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *FileStorage) CreateUpload(id string) error {
+	if err := os.MkdirAll(s.uploadPath, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.uploadPath, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// AppendUpload writes chunk at offset, which must equal the bytes stored so
+// far. Bytes received before a read error are kept so the upload can resume.
+func (s *FileStorage) AppendUpload(id string, offset int64, chunk io.Reader) (int64, error) {
+	f, err := os.OpenFile(filepath.Join(s.uploadPath, id), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() != offset {
+		return 0, ErrUploadOffset
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(f, chunk)
+}
+
+func (s *FileStorage) OpenUpload(id string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.uploadPath, id))
+}
+
+func (s *FileStorage) DeleteUpload(id string) error {
+	err := os.Remove(filepath.Join(s.uploadPath, id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStorage) saveFile(basePath string, file io.Reader, filename string) (*StoredFile, error) {
+	// Names must not reach outside basePath
+	if filename == "" || filename == "." || filename == ".." || filename != filepath.Base(filename) {
+		return nil, ErrInvalidFilename
+	}
+
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(basePath, 0755); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Create file path
@@ -50,15 +247,58 @@ func (s *FileStorage) saveFile(basePath string, file io.Reader, filename string)
 	// Create file
 	dst, err := os.Create(filePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer dst.Close()
 
-	// Copy file contents
-	if _, err := io.Copy(dst, file); err != nil {
+	// Copy file contents, hashing them on the way
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), file)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err != nil {
 		os.Remove(filePath) // Clean up on error
+		return nil, err
+	}
+
+	return &StoredFile{Path: filePath, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// UniqueName returns a random name to store an upload under, keeping the
+// extension of the client's filename. Client filenames are not unique, so
+// storing files under them lets one upload overwrite another.
+func UniqueName(filename string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + strings.ToLower(filepath.Ext(filename)), nil
+}
+
+// Checksum returns the hex SHA-256 of everything read from r
+func Checksum(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Verify re-hashes a stored file and compares it with the expected checksum
+func Verify(store Storage, path, expected string) error {
+	f, err := store.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	return filePath, nil
+	sum, err := Checksum(f)
+	if err != nil {
+		return err
+	}
+	if sum != expected {
+		return ErrChecksumMismatch
+	}
+	return nil
 }
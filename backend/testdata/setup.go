@@ -13,6 +13,7 @@ import (
 	"sample-exchange/backend/services/samplepack"
 	"sample-exchange/backend/services/submission"
 	"sample-exchange/backend/services/user"
+	"sample-exchange/backend/storage"
 )
 
 type TestData struct {
@@ -39,8 +40,12 @@ func Setup(cfg *config.Config) (*TestData, error) {
 
 	// Create services
 	userSvc := user.NewService()
-	packSvc := samplepack.NewService(cfg)
-	submissionSvc := submission.NewService(cfg, packSvc)
+	store, err := storage.NewStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup storage: %w", err)
+	}
+	packSvc := samplepack.NewService(cfg, store)
+	submissionSvc := submission.NewService(cfg, packSvc, store)
 
 	// Create test user
 	testUser, err := userSvc.CreateTestUser()